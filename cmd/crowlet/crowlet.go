@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,7 +26,7 @@ var (
 )
 
 func beforeApp(c *cli.Context) error {
-	if c.GlobalBool("debug") {
+	if c.GlobalBool("debug") || strings.EqualFold(c.GlobalString("log-level"), "debug") {
 		log.SetLevel(log.DebugLevel)
 	} else if c.GlobalBool("quiet") || c.GlobalBool("summary-only") {
 		log.SetLevel(log.FatalLevel)
@@ -29,7 +36,7 @@ func beforeApp(c *cli.Context) error {
 		log.SetFormatter(&log.JSONFormatter{})
 	}
 
-	if c.NArg() < 1 {
+	if c.NArg() < 1 && c.GlobalString("urls-file") == "" {
 		log.Error("sitemap url required")
 		cli.ShowAppHelpAndExit(c, 2)
 	}
@@ -63,7 +70,7 @@ func main() {
 	app.Version = VERSION
 	app.Usage = "a basic sitemap.xml crawler"
 	app.Action = start
-	app.UsageText = "[global options] sitemap-url"
+	app.UsageText = "[global options] sitemap-url | --urls-file path"
 	app.Before = beforeApp
 	app.After = afterApp
 	app.Flags = []cli.Flag{
@@ -75,10 +82,153 @@ func main() {
 			Name:  "crawl-images",
 			Usage: "follow and test image links ('img' tags src)",
 		},
+		cli.BoolFlag{
+			Name:  "crawl-stylesheets",
+			Usage: "follow and test stylesheets ('link' tags href with a 'stylesheet' rel)",
+		},
+		cli.BoolFlag{
+			Name:  "crawl-scripts",
+			Usage: "follow and test scripts ('script' tags src)",
+		},
+		cli.BoolFlag{
+			Name:  "crawl-alternates",
+			Usage: "follow and test alternate and canonical links ('link' tags href, including hreflang)",
+		},
 		cli.BoolFlag{
 			Name:  "crawl-external",
 			Usage: "follow and test external links. Use in combination with 'follow-hyperlinks' and/or 'follow-images'",
 		},
+		cli.StringFlag{
+			Name:  "urls-file",
+			Usage: "crawl the URLs listed in the given file, one per line, or '-' for stdin, instead of a sitemap",
+		},
+		cli.StringFlag{
+			Name:  "changed-since",
+			Usage: "only crawl the sitemap URLs whose lastmod is after the given date, like 2006-01-02 or 2006-01-02T15:04:05Z",
+		},
+		cli.StringFlag{
+			Name:  "lastmod-state",
+			Usage: "only crawl the sitemap URLs whose lastmod changed since the run that saved the given state file, which is then updated",
+		},
+		cli.BoolFlag{
+			Name:  "if-modified-since",
+			Usage: "send the sitemap lastmod of URLs as If-Modified-Since, '304 Not Modified' responses being healthy",
+		},
+		cli.BoolFlag{
+			Name:  "list-changed",
+			Usage: "list the changed sitemap URLs instead of crawling them. Use in combination with 'changed-since' or 'lastmod-state'",
+		},
+		cli.IntFlag{
+			Name:  "sitemap-depth",
+			Usage: "maximum number of nested sitemap indexes to follow",
+			Value: 1,
+		},
+		cli.BoolFlag{
+			Name:  "strict-sitemap",
+			Usage: "fail if a nested sitemap cannot be loaded, lists an invalid URL or exceeds the sitemaps.org size limits",
+		},
+		cli.BoolFlag{
+			Name:  "validate-sitemap",
+			Usage: "warn about lastmod dates in the future or invalid, unknown changefreq values and priorities outside of 0.0 to 1.0",
+		},
+		cli.BoolFlag{
+			Name:  "check-duplicates",
+			Usage: "warn about URLs listed more than once in the sitemap",
+		},
+		cli.StringSliceFlag{
+			Name:  "soft-404",
+			Usage: "report 200 pages whose body contains this text, ignoring case, as soft 404 pages. Can be repeated",
+		},
+		cli.BoolFlag{
+			Name:  "fail-on-empty-body",
+			Usage: "report 200 pages without any body byte, like broken templates, as errors",
+		},
+		cli.BoolFlag{
+			Name:  "fail-on-redirects",
+			Usage: "consider sitemap URLs redirecting to another URL as errors, rather than only reporting them",
+		},
+		cli.BoolFlag{
+			Name:  "detect-mixed-content",
+			Usage: "report http images, stylesheets and scripts referenced from https pages",
+		},
+		cli.BoolFlag{
+			Name:  "check-content-types",
+			Usage: "report pages not served as 'text/html', and images not served as 'image/*'",
+		},
+		cli.IntFlag{
+			Name:  "link-depth",
+			Usage: "maximum number of link hops to follow from sitemap URLs. Use in combination with 'crawl-hyperlinks' and/or 'crawl-images'",
+			Value: 1,
+		},
+		cli.BoolFlag{
+			Name:  "normalize-urls",
+			Usage: "crawl linked URLs differing only by fragment or query parameters order once",
+		},
+		cli.StringSliceFlag{
+			Name:  "normalize",
+			Usage: "normalize sitemap and linked URLs before deduplicating and crawling them, 'strip-fragment' or 'strip-utm'. Can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "link-prefix",
+			Usage: "only crawl linked URLs whose path starts with this prefix, like '/docs/'. Can be repeated",
+		},
+		cli.BoolFlag{
+			Name:  "json-links",
+			Usage: "extract links from the URLs and absolute paths found in 'application/json' responses. Use in combination with 'crawl-hyperlinks'",
+		},
+		cli.BoolFlag{
+			Name:  "check-fragments",
+			Usage: "report hyperlinks whose #fragment is not an id or anchor name of their crawled target page",
+		},
+		cli.BoolFlag{
+			Name:  "respect-robots-txt",
+			Usage: "skip linked URLs disallowed by robots.txt, and honor its crawl delay",
+		},
+		cli.BoolFlag{
+			Name:  "robots-txt-sitemap",
+			Usage: "also apply robots.txt rules to sitemap URLs. Use in combination with 'respect-robots-txt'",
+		},
+		cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "only crawl sitemap URLs matching this regular expression. Can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "do not crawl sitemap URLs matching this regular expression. Can be repeated, and wins over 'include'",
+		},
+		cli.IntFlag{
+			Name:  "sample",
+			Usage: "only crawl a random sample of this number of sitemap URLs, picked after filtering. Disabled if 0",
+		},
+		cli.Int64Flag{
+			Name:  "sample-seed",
+			Usage: "seed picking the 'sample' URLs, to crawl the same sample on every run. Random if 0",
+		},
+		cli.IntFlag{
+			Name:  "max-urls",
+			Usage: "only crawl the first sitemap URLs remaining after filtering and sampling, up to this number. Unlimited if 0",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the URLs that would be crawled, after filtering and overrides, without crawling them",
+		},
+		cli.IntFlag{
+			Name:  "max-crawl-duration",
+			Usage: "maximum duration of a crawl iteration in seconds, after which it stops with partial results. Unlimited if 0",
+		},
+		cli.StringFlag{
+			Name:  "window",
+			Usage: "local time window allowed for crawling, as '[days] start-end' hours like 'mon-fri 22-6', pausing outside of it",
+		},
+		cli.IntFlag{
+			Name:  "max-failures",
+			Usage: "number of failed URLs, following 'fail-on', after which a crawl iteration stops with partial results. Unlimited if 0",
+		},
+		cli.IntFlag{
+			Name:  "grace-period",
+			Usage: "time in milliseconds given to in-flight requests to finish once a crawl is interrupted or stopped, before aborting them",
+			Value: 5000,
+		},
 		cli.BoolFlag{
 			Name:  "forever,f",
 			Usage: "crawl the sitemap's URLs forever... or until stopped",
@@ -100,11 +250,119 @@ func main() {
 			EnvVar: "CRAWL_THROTTLE",
 			Value:  5,
 		},
+		cli.IntFlag{
+			Name:   "per-host-throttle",
+			Usage:  "maximum number of http requests to do at once to a single host. Unlimited if 0",
+			EnvVar: "CRAWL_PER_HOST_THROTTLE",
+			Value:  0,
+		},
+		cli.Float64Flag{
+			Name:   "max-rps",
+			Usage:  "maximum number of http requests per second. Unlimited if 0",
+			EnvVar: "CRAWL_MAX_RPS",
+			Value:  0,
+		},
+		cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of URLs handled at once, waiting for robots.txt delays or 'max-rps' while at most 'throttle' requests are sent. Same as 'throttle' if 0",
+			Value: 0,
+		},
+		cli.IntFlag{
+			Name:  "ramp-up",
+			Usage: "duration over which the start of the first 'throttle' requests is spread, in milliseconds. All start at once if 0",
+			Value: 0,
+		},
+		cli.IntFlag{
+			Name:  "breaker-failures",
+			Usage: "pause the requests to a host after that many consecutive network errors or 5xx responses. Never paused if 0",
+			Value: 0,
+		},
+		cli.IntFlag{
+			Name:  "breaker-cooldown",
+			Usage: "duration of the pause of a failing host before a request probes it, in milliseconds. Use in combination with 'breaker-failures'",
+			Value: 30000,
+		},
 		cli.IntFlag{
 			Name:  "timeout,y",
 			Usage: "timeout duration for requests, in milliseconds",
 			Value: 20000,
 		},
+		cli.IntFlag{
+			Name:  "retries,r",
+			Usage: "number of retries for requests failing with a network error or a 429, 502, 503 or 504 status",
+			Value: 0,
+		},
+		cli.IntFlag{
+			Name:  "retry-backoff",
+			Usage: "base delay before retrying a failed request, in milliseconds. Doubles at each retry",
+			Value: 500,
+		},
+		cli.IntFlag{
+			Name:  "max-retry-after",
+			Usage: "maximum delay honored from the Retry-After header of 429 and 503 responses before retrying, in milliseconds",
+			Value: 60000,
+		},
+		cli.BoolFlag{
+			Name:  "http2",
+			Usage: "attempt HTTP/2 on every connection, and consider responses over another protocol as errors",
+		},
+		cli.BoolFlag{
+			Name:  "disable-http2",
+			Usage: "only use HTTP/1.1",
+		},
+		cli.IntFlag{
+			Name:  "max-idle-conns",
+			Usage: "maximum number of idle connections kept open. Defaults to net/http's if 0",
+		},
+		cli.IntFlag{
+			Name:  "max-idle-conns-per-host",
+			Usage: "maximum number of idle connections kept open per host. Defaults to net/http's if 0",
+		},
+		cli.BoolFlag{
+			Name:  "disable-keep-alives",
+			Usage: "open a new connection for every request",
+		},
+		cli.StringFlag{
+			Name:   "proxy",
+			Usage:  "url of the http, https or socks5 proxy to use, including for sitemaps. Defaults to HTTP_PROXY and HTTPS_PROXY",
+			EnvVar: "CRAWL_PROXY",
+		},
+		cli.StringFlag{
+			Name:   "ca-file",
+			Usage:  "path of a PEM bundle of certificate authorities to trust, in addition to the system ones",
+			EnvVar: "CRAWL_CA_FILE",
+		},
+		cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "do not verify TLS certificates. Do not use in production",
+		},
+		cli.StringSliceFlag{
+			Name:  "resolve",
+			Usage: "connect to another address for a host, keeping its Host header and TLS name, as 'host[:port]=ip[:port]'. Can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "dial-network",
+			Usage: "network used to connect to hosts, 'tcp4' or 'tcp6' to only use IPv4 or IPv6, or 'tcp' to use either",
+			Value: "tcp",
+		},
+		cli.StringFlag{
+			Name:  "method",
+			Usage: "http method used for requests, 'GET' or 'HEAD'. HEAD falls back to GET if not allowed, and is not used for pages whose links are crawled",
+			Value: "GET",
+		},
+		cli.Int64Flag{
+			Name:  "max-body-bytes",
+			Usage: "maximum number of bytes read from response bodies. Unlimited if 0",
+		},
+		cli.BoolFlag{
+			Name:  "discard-body",
+			Usage: "do not read response bodies, unless their links are crawled",
+		},
+		cli.IntFlag{
+			Name:  "max-redirects",
+			Usage: "maximum number of redirects to follow for a URL before considered an error",
+			Value: crawler.DefaultMaxRedirects,
+		},
 		cli.BoolFlag{
 			Name:  "quiet,silent,q",
 			Usage: "suppress all normal output",
@@ -113,10 +371,19 @@ func main() {
 			Name:  "json,j",
 			Usage: "output using JSON format (experimental)",
 		},
+		cli.StringFlag{
+			Name:  "success-codes",
+			Usage: "comma separated status codes of successful responses, part of the response time statistics, like '200,201,204' (default: 200)",
+		},
+		cli.StringFlag{
+			Name: "fail-on",
+			Usage: "comma separated status codes and classes considered failures, like '404,5xx'." +
+				" Defaults to any status code other than 200 and 3xx redirects",
+		},
 		cli.IntFlag{
 			Name: "non-200-error,e",
 			Usage: "error code to use if any non-200 response if" +
-				" encountered",
+				" encountered, or any response matching 'fail-on' if set",
 			Value: 1,
 		},
 		cli.IntFlag{
@@ -131,10 +398,75 @@ func main() {
 				" considered an error",
 			Value: 0,
 		},
+		cli.IntFlag{
+			Name:  "slow-threshold",
+			Usage: "response time of 200 URLs, in milliseconds, from which they are reported as slow. Disabled if 0",
+		},
+		cli.IntFlag{
+			Name:  "slowest",
+			Usage: "number of slowest URLs, whatever their status code, listed after the summary. Disabled if 0",
+		},
+		cli.BoolFlag{
+			Name:  "warm-up",
+			Usage: "send an uncounted request to each host before crawling, so that cold starts do not skew response times",
+		},
+		cli.IntFlag{
+			Name:  "fail-on-slow",
+			Usage: "number of slow URLs from which the 'response-time-error' code is used. Use in combination with 'slow-threshold'. Disabled if 0",
+		},
+		cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "record crawled URLs to the given file as they are crawled, to resume an interrupted crawl with 'resume-from'",
+		},
+		cli.StringFlag{
+			Name:  "resume-from",
+			Usage: "do not crawl again URLs recorded in the given checkpoint file, reporting their recorded results instead",
+		},
+		cli.StringFlag{
+			Name:  "dead-letter-file",
+			Usage: "append each failing URL to the given file as a JSON object per line as soon as crawled, to retry them later",
+		},
+		cli.StringFlag{
+			Name:  "json-file",
+			Usage: "write the crawling statistics in JSON format to the given file, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "summary-file",
+			Usage: "write a single JSON object summarizing the crawl, and whether it passed, to the given file, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "broken-links-csv",
+			Usage: "write the pages linking to non-200 URLs to the given file in CSV format, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "headers-csv",
+			Usage: "write the 'capture-header' headers of each crawled URL to the given file in CSV format, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "csv-file",
+			Usage: "write each crawled URL to the given file in CSV format as soon as crawled, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "ndjson-file",
+			Usage: "write each crawled URL to the given file as a JSON object per line as soon as crawled, or '-' for stdout",
+		},
+		cli.StringFlag{
+			Name:  "junit-file",
+			Usage: "write a JUnit XML report with a test case per crawled URL to the given file, or '-' for stdout",
+		},
 		cli.BoolFlag{
 			Name:  "summary-only",
 			Usage: "print only the summary",
 		},
+		cli.BoolFlag{
+			Name:  "preserve-order",
+			Usage: "log and write crawled URLs in sitemap order rather than as completed, requests still being concurrent",
+		},
+		cli.StringFlag{
+			Name:   "override-scheme",
+			Usage:  "override the scheme used in sitemap urls, 'http' or 'https'",
+			EnvVar: "CRAWL_SCHEME",
+		},
 		cli.StringFlag{
 			Name:   "override-host",
 			Usage:  "override the hostname used in sitemap urls",
@@ -150,6 +482,58 @@ func main() {
 			Usage:  "password for http basic authentication",
 			EnvVar: "CRAWL_HTTP_PASSWORD",
 		},
+		cli.StringFlag{
+			Name:   "bearer-token",
+			Usage:  "token sent as an 'Authorization: Bearer' header, exclusive with basic authentication",
+			EnvVar: "CRAWL_HTTP_BEARER_TOKEN",
+		},
+		cli.StringSliceFlag{
+			Name:  "host-auth",
+			Usage: "basic authentication credentials for a single host, as 'host=user:pass'. Can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "host-bearer-token",
+			Usage: "bearer token for a single host, as 'host=token'. Can be repeated",
+		},
+		cli.StringFlag{
+			Name:   "user-agent",
+			Usage:  "user agent sent with http requests",
+			EnvVar: "CRAWL_USER_AGENT",
+			Value:  "crowlet/" + VERSION,
+		},
+		cli.StringSliceFlag{
+			Name:  "header,H",
+			Usage: "additional http header to send, as 'Name: value'. Can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "capture-header",
+			Usage: "name of a response header to capture, reported with 'headers-csv'. Can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "cache-status-header",
+			Usage: "name of a response header telling whether it was served from cache, like X-Cache, to count cache hits and misses",
+		},
+		cli.StringSliceFlag{
+			Name:  "cookie",
+			Usage: "cookie to send to the sitemap hosts, as 'name=value'. Can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "login-url",
+			Usage: "url of a login form to submit before crawling, whose session cookies are sent with requests",
+		},
+		cli.StringSliceFlag{
+			Name:  "login-form",
+			Usage: "login form field, as 'name=value'. Use in combination with 'login-url'. Can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "login-method",
+			Usage: "http method of the login form, POST or GET",
+			Value: "POST",
+		},
+		cli.StringFlag{
+			Name:  "login-cookie",
+			Usage: "name of the session cookie the login must set, failing before crawling otherwise",
+		},
 		cli.StringFlag{
 			Name:  "pre-cmd",
 			Usage: "command(s) to run before starting crawler",
@@ -159,28 +543,45 @@ func main() {
 			Usage: "command(s) to run after crawler finishes",
 		},
 		cli.BoolFlag{
-			Name:  "debug",
+			Name:  "debug,verbose",
 			Usage: "run in debug mode",
 		},
+		cli.StringFlag{
+			Name:  "log-level",
+			Usage: "minimum level of the crawl logs, the summary being printed whatever the level: debug, info, warn, error or silent",
+		},
 	}
 
 	app.Run(os.Args)
 	os.Exit(exitCode)
 }
 
-func addInterruptHandlers() chan struct{} {
-	stop := make(chan struct{})
-	osSignal := make(chan os.Signal)
+// addInterruptHandlers returns a context derived from parent, cancelled when
+// an interrupt signal is received. Signals are only handled by the command,
+// the crawler package being driven by the context.
+func addInterruptHandlers(parent context.Context) context.Context {
+	// Buffered so that a signal sent before the goroutine waits is not lost
+	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt, syscall.SIGTERM)
-	signal.Notify(osSignal, os.Interrupt, syscall.SIGINT)
+
+	return cancelOnSignal(parent, osSignal)
+}
+
+// cancelOnSignal returns a context derived from parent, cancelled when a
+// signal is received from osSignal
+func cancelOnSignal(parent context.Context, osSignal <-chan os.Signal) context.Context {
+	ctx, stop := context.WithCancel(parent)
 
 	go func() {
-		<-osSignal
-		log.Warn("Interrupt signal received")
-		close(stop)
+		select {
+		case <-osSignal:
+			log.Warn("Interrupt signal received")
+			stop()
+		case <-ctx.Done():
+		}
 	}()
 
-	return stop
+	return ctx
 }
 
 func runMainLoop(urls []string, config crawler.CrawlConfig, iterations int, forever bool, waitInterval int) (stats crawler.CrawlStats) {
@@ -189,20 +590,26 @@ func runMainLoop(urls []string, config crawler.CrawlConfig, iterations int, fore
 			time.Sleep(time.Duration(waitInterval) * time.Second)
 		}
 
-		quit := addInterruptHandlers()
-		itStats, err := crawler.AsyncCrawl(urls, config, quit)
+		ctx := addInterruptHandlers(context.Background())
+		itStats, err := crawler.AsyncCrawlContext(ctx, urls, config)
 
 		stats = crawler.MergeCrawlStats(stats, itStats)
+		if forever {
+			// Bound the memory of endless crawls, the results and the
+			// response times of the percentiles covering the last iteration
+			stats.Results = itStats.Results
+			stats.Times200 = itStats.Times200
+		}
+
+		// Only the first iteration resumes an interrupted crawl
+		config.ResumeFrom = ""
 
 		if err != nil {
 			log.Warn(err)
 		}
 
-		select {
-		case <-quit:
+		if ctx.Err() != nil {
 			return
-		default:
-			// Don't block main loop
 		}
 	}
 
@@ -211,38 +618,248 @@ func runMainLoop(urls []string, config crawler.CrawlConfig, iterations int, fore
 
 func start(c *cli.Context) error {
 	sitemapURL := c.Args().Get(0)
-	log.Info("Crawling ", sitemapURL)
+	urlsFile := c.String("urls-file")
+	if urlsFile != "" {
+		log.Info("Crawling URLs listed in ", urlsFile)
+	} else {
+		log.Info("Crawling ", sitemapURL)
+	}
 
-	urls, err := crawler.GetSitemapUrlsAsStrings(sitemapURL)
-	if err != nil {
-		log.Fatal(err)
+	httpConfig := crawler.HTTPConfig{
+		User:                c.String("user"),
+		Pass:                c.String("pass"),
+		BearerToken:         c.String("bearer-token"),
+		HostAuth:            parseHostAuth(c.StringSlice("host-auth"), c.StringSlice("host-bearer-token")),
+		UserAgent:           c.String("user-agent"),
+		Headers:             parseHeaders(c.StringSlice("header")),
+		Cookies:             parseCookies(c.StringSlice("cookie")),
+		Timeout:             time.Duration(c.Int("timeout")) * time.Millisecond,
+		MaxRedirects:        c.Int("max-redirects"),
+		Method:              strings.ToUpper(c.String("method")),
+		MaxBodyBytes:        c.Int64("max-body-bytes"),
+		DiscardBody:         c.Bool("discard-body"),
+		ForceHTTP2:          c.Bool("http2"),
+		DisableHTTP2:        c.Bool("disable-http2"),
+		MaxIdleConns:        c.Int("max-idle-conns"),
+		MaxIdleConnsPerHost: c.Int("max-idle-conns-per-host"),
+		DisableKeepAlives:   c.Bool("disable-keep-alives"),
+		Proxy:               c.String("proxy"),
+		CAFile:              c.String("ca-file"),
+		InsecureSkipVerify:  c.Bool("insecure"),
+		HostResolve:         parseHostResolve(c.StringSlice("resolve")),
+		DialNetwork:         c.String("dial-network"),
+		CaptureHeaders:      c.StringSlice("capture-header"),
+		CacheStatusHeader:   c.String("cache-status-header"),
+		Soft404Patterns:     c.StringSlice("soft-404"),
+		Retry: crawler.RetryConfig{
+			MaxRetries:    c.Int("retries"),
+			BaseBackoff:   time.Duration(c.Int("retry-backoff")) * time.Millisecond,
+			MaxRetryAfter: time.Duration(c.Int("max-retry-after")) * time.Millisecond,
+		},
+	}
+	sitemapLoader := crawler.SitemapLoader{HTTP: httpConfig, Concurrency: c.Int("throttle")}
+
+	var urls []string
+	var entries []crawler.SitemapEntry
+	if urlsFile != "" {
+		urls = readUrlsFile(urlsFile)
+	} else {
+		urls, entries = readSitemap(c, sitemapLoader, sitemapURL)
+	}
+
+	if c.Bool("list-changed") {
+		for _, url := range urls {
+			fmt.Println(url)
+		}
+		return nil
+	} else if len(urls) == 0 && isIncremental(c) {
+		log.Info("No URL changed, nothing to crawl")
+		return nil
 	}
 	log.Info("Found ", len(urls), " URL(s)")
 
 	config := crawler.CrawlConfig{
-		Throttle: c.Int("throttle"),
-		Host:     c.String("override-host"),
-		HTTP: crawler.HTTPConfig{
-			User:    c.String("user"),
-			Pass:    c.String("pass"),
-			Timeout: time.Duration(c.Int("timeout")) * time.Millisecond,
-		},
+		Throttle:        c.Int("throttle"),
+		PerHostThrottle: c.Int("per-host-throttle"),
+		MaxRPS:          c.Float64("max-rps"),
+		RampUp:          time.Duration(c.Int("ramp-up")) * time.Millisecond,
+		Workers:         c.Int("workers"),
+		BreakerFailures: c.Int("breaker-failures"),
+		BreakerCooldown: time.Duration(c.Int("breaker-cooldown")) * time.Millisecond,
+		Scheme:          c.String("override-scheme"),
+		Host:            c.String("override-host"),
+		HTTP:            httpConfig,
 		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
 			Get: crawler.HTTPGet,
 		},
+		IncludePatterns:      c.StringSlice("include"),
+		SampleN:              c.Int("sample"),
+		SampleSeed:           c.Int64("sample-seed"),
+		MaxURLs:              c.Int("max-urls"),
+		ExcludePatterns:      c.StringSlice("exclude"),
+		RespectRobotsTxt:     c.Bool("respect-robots-txt"),
+		RobotsTxtSitemapUrls: c.Bool("robots-txt-sitemap"),
+		SlowThreshold:        time.Duration(c.Int("slow-threshold")) * time.Millisecond,
+		FailOnSlowUrls:       c.Int("fail-on-slow"),
+		Checkpoint:           c.String("checkpoint"),
+		ResumeFrom:           c.String("resume-from"),
+		MaxCrawlDuration:     time.Duration(c.Int("max-crawl-duration")) * time.Second,
+		MaxFailures:          c.Int("max-failures"),
+		GracePeriod:          time.Duration(c.Int("grace-period")) * time.Millisecond,
+		DetectMixedContent:   c.Bool("detect-mixed-content"),
+		FailOnRedirects:      c.Bool("fail-on-redirects"),
+		FailOnEmptyBody:      c.Bool("fail-on-empty-body"),
+		PreserveOrder:        c.Bool("preserve-order"),
+		WarmUp:               c.Bool("warm-up"),
 		Links: crawler.CrawlLinksConfig{
-			CrawlExternalLinks: c.Bool("crawl-external"),
-			CrawlImages:        c.Bool("crawl-images"),
-			CrawlHyperlinks:    c.Bool("crawl-hyperlinks"),
+			CrawlExternalLinks:  c.Bool("crawl-external"),
+			CrawlImages:         c.Bool("crawl-images"),
+			CrawlHyperlinks:     c.Bool("crawl-hyperlinks"),
+			CrawlAlternateLinks: c.Bool("crawl-alternates"),
+			CrawlStylesheets:    c.Bool("crawl-stylesheets"),
+			CrawlScripts:        c.Bool("crawl-scripts"),
+			MaxLinkDepth:        c.Int("link-depth"),
+			NormalizeURLs:       c.Bool("normalize-urls"),
+			LinkPrefixAllow:     c.StringSlice("link-prefix"),
+			CheckFragments:      c.Bool("check-fragments"),
 		},
 	}
 
+	if loginURL := c.String("login-url"); loginURL != "" {
+		config.HTTP.Login = &crawler.LoginConfig{
+			URL:            loginURL,
+			Method:         strings.ToUpper(c.String("login-method")),
+			Form:           parseForm(c.StringSlice("login-form")),
+			ExpectedCookie: c.String("login-cookie"),
+		}
+	}
+
+	if c.Bool("if-modified-since") {
+		config.HTTP.IfModifiedSince = crawler.ModifiedSince(entries)
+	}
+
+	if c.Bool("json-links") {
+		config.HTTP.LinkExtractors = map[string]crawler.LinkExtractor{
+			"application/json": crawler.ExtractJSONLinks,
+		}
+	}
+
+	if config.HTTP.ForceHTTP2 && config.HTTP.DisableHTTP2 {
+		log.Fatal("Options 'http2' and 'disable-http2' are mutually exclusive")
+	}
+
+	if config.HTTP.Method != http.MethodGet && config.HTTP.Method != http.MethodHead {
+		log.Fatal("Invalid http method: ", c.String("method"))
+	}
+
+	if c.Bool("check-content-types") {
+		config.Links.ExpectedContentTypes = crawler.DefaultExpectedContentTypes
+	}
+
+	var err error
+	if logLevel := c.GlobalString("log-level"); logLevel != "" {
+		config.LogLevel, err = crawler.ParseLogLevel(logLevel)
+		if err != nil {
+			log.Fatal("Invalid log level: ", err)
+		}
+	}
+
+	config.Normalize, err = crawler.ParseNormalizers(c.StringSlice("normalize"))
+	if err != nil {
+		log.Fatal("Invalid normalizer: ", err)
+	}
+
+	if window := c.String("window"); window != "" {
+		config.Window, err = crawler.ParseTimeWindow(window)
+		if err != nil {
+			log.Fatal("Invalid time window: ", err)
+		}
+	}
+
+	if successCodes := c.String("success-codes"); successCodes != "" {
+		config.SuccessCodes, err = crawler.ParseStatusCodes(successCodes)
+		if err != nil {
+			log.Fatal("Invalid success codes: ", err)
+		}
+	}
+
+	if failOn := c.String("fail-on"); failOn != "" {
+		config.FailOn, err = crawler.ParseStatusPolicy(failOn)
+		if err != nil {
+			log.Fatal("Invalid failure policy: ", err)
+		}
+	}
+
+	if _, err := crawler.FilterUrls(urls, config); err != nil {
+		log.Fatal("Invalid URL pattern: ", err)
+	}
+
+	if _, err := crawler.ResolveUrls(nil, config); err != nil {
+		log.Fatal("Invalid URL override: ", err)
+	}
+
+	if c.Bool("dry-run") {
+		resolvedUrls, _ := crawler.ResolveUrls(urls, config)
+		for _, resolvedURL := range resolvedUrls {
+			fmt.Println(resolvedURL)
+		}
+		return nil
+	}
+
+	var csvWriter *crawler.CSVResultWriter
+	if csvFile := c.String("csv-file"); csvFile != "" {
+		var output io.Writer = os.Stdout
+		if csvFile != "-" {
+			file, err := os.Create(csvFile)
+			if err != nil {
+				log.Fatal("Failed to create CSV file: ", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		csvWriter, err = crawler.NewCSVResultWriter(output)
+		if err != nil {
+			log.Fatal("Failed to write CSV file: ", err)
+		}
+		config.OnResult = csvWriter.OnResult
+	}
+
+	var ndjsonWriter *crawler.NDJSONWriter
+	if ndjsonFile := c.String("ndjson-file"); ndjsonFile != "" {
+		var output io.Writer = os.Stdout
+		if ndjsonFile != "-" {
+			file, err := os.Create(ndjsonFile)
+			if err != nil {
+				log.Fatal("Failed to create NDJSON file: ", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		ndjsonWriter = crawler.NewNDJSONWriter(output)
+		config.OnResult = chainOnResult(config.OnResult, ndjsonWriter.OnResult)
+	}
+
+	if deadLetterFile := c.String("dead-letter-file"); deadLetterFile != "" {
+		file, err := os.OpenFile(deadLetterFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal("Failed to open dead letter file: ", err)
+		}
+		defer file.Close()
+		config.DeadLetterWriter = file
+	}
+
 	stats := runMainLoop(urls, config, c.Int("iterations"), c.Bool("forever"), c.Int("wait-interval"))
+	saveLastModState(c, entries, stats)
 	if !c.GlobalBool("quiet") {
 		if c.GlobalBool("json") {
 			crawler.PrintJSONSummary(stats)
 		} else {
 			crawler.PrintSummary(stats)
+			if slowest := c.Int("slowest"); slowest > 0 {
+				crawler.PrintSlowest(stats, slowest)
+			}
 		}
 
 		if c.Bool("summary-only") {
@@ -250,7 +867,57 @@ func start(c *cli.Context) error {
 		}
 	}
 
-	if stats.Total != stats.StatusCodes[200] {
+	if jsonFile := c.String("json-file"); jsonFile != "" {
+		err := writeReportFile(jsonFile, stats, crawler.WriteStatsJSON)
+		if err != nil {
+			log.Error("Failed to write JSON statistics: ", err)
+		}
+	}
+
+	if summaryFile := c.String("summary-file"); summaryFile != "" {
+		err := writeReportFile(summaryFile, stats, func(w io.Writer, stats crawler.CrawlStats) error {
+			return crawler.WriteSummary(w, stats, config)
+		})
+		if err != nil {
+			log.Error("Failed to write summary: ", err)
+		}
+	}
+
+	if csvWriter != nil && csvWriter.Error() != nil {
+		log.Error("Failed to write CSV results: ", csvWriter.Error())
+	}
+
+	if ndjsonWriter != nil && ndjsonWriter.Error() != nil {
+		log.Error("Failed to write NDJSON results: ", ndjsonWriter.Error())
+	}
+
+	if junitFile := c.String("junit-file"); junitFile != "" {
+		err := writeReportFile(junitFile, stats, func(w io.Writer, stats crawler.CrawlStats) error {
+			return crawler.WriteJUnitReport(w, stats, config)
+		})
+		if err != nil {
+			log.Error("Failed to write JUnit report: ", err)
+		}
+	}
+
+	if headersFile := c.String("headers-csv"); headersFile != "" {
+		err := writeReportFile(headersFile, stats, func(w io.Writer, stats crawler.CrawlStats) error {
+			return crawler.WriteHeadersCSV(w, stats, httpConfig.CaptureHeaders)
+		})
+		if err != nil {
+			log.Error("Failed to write headers report: ", err)
+		}
+	}
+
+	if brokenLinksFile := c.String("broken-links-csv"); brokenLinksFile != "" {
+		err := writeReportFile(brokenLinksFile, stats, crawler.WriteBrokenLinksCSV)
+		if err != nil {
+			log.Error("Failed to write broken links report: ", err)
+		}
+	}
+
+	if stats.Failures(config.FailurePolicy()) > 0 || len(stats.ContentTypeMismatches) > 0 || len(stats.Soft404Urls) > 0 ||
+		len(stats.EmptyBodyUrls) > 0 || len(stats.MixedContent) > 0 || len(stats.BrokenFragments) > 0 || (config.FailOnRedirects && len(stats.RedirectedUrls) > 0) {
 		exitCode = c.Int("non-200-error")
 		return nil
 	}
@@ -261,5 +928,248 @@ func start(c *cli.Context) error {
 		exitCode = c.Int("response-time-error")
 	}
 
+	if config.FailOnSlowUrls > 0 && len(stats.SlowUrls) >= config.FailOnSlowUrls {
+		log.Warn(len(stats.SlowUrls), " URL(s) were slower than ", config.SlowThreshold)
+		exitCode = c.Int("response-time-error")
+	}
+
 	return nil
 }
+
+// chainOnResult returns a result callback calling first, if not nil, then next
+func chainOnResult(first func(crawler.CrawlResult, crawler.Progress),
+	next func(crawler.CrawlResult, crawler.Progress)) func(crawler.CrawlResult, crawler.Progress) {
+
+	if first == nil {
+		return next
+	}
+
+	return func(result crawler.CrawlResult, progress crawler.Progress) {
+		first(result, progress)
+		next(result, progress)
+	}
+}
+
+// writeReportFile writes a report of the crawling statistics to the file at
+// path, or to stdout if path is '-'
+func writeReportFile(path string, stats crawler.CrawlStats,
+	write func(io.Writer, crawler.CrawlStats) error) error {
+
+	if path == "-" {
+		return write(os.Stdout, stats)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return write(file, stats)
+}
+
+// readSitemap returns the URLs of the sitemap, exiting if it cannot be read,
+// along with their entries if their lastmod is used. In incremental mode, only
+// the URLs of the changed entries are returned.
+func readSitemap(c *cli.Context, loader crawler.SitemapLoader, sitemapURL string) (urls []string,
+	entries []crawler.SitemapEntry) {
+	var err error
+	if isIncremental(c) || c.Bool("if-modified-since") {
+		entries, err = loader.GetSitemapEntriesRecursive(sitemapURL, c.Int("sitemap-depth"))
+		urls = crawler.EntryUrls(entries)
+	} else {
+		urls, err = loader.GetSitemapUrlsRecursiveAsStrings(sitemapURL, c.Int("sitemap-depth"))
+	}
+
+	if err != nil {
+		if _, partial := err.(crawler.SitemapErrors); !partial || len(urls) == 0 || c.Bool("strict-sitemap") {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
+
+	if c.Bool("check-duplicates") {
+		warnDuplicateUrls(loader, sitemapURL)
+	}
+
+	if c.Bool("validate-sitemap") {
+		warnSitemapMetadata(loader, sitemapURL)
+	}
+
+	if isIncremental(c) {
+		entries = changedEntries(c, entries)
+		log.Info(len(entries), " of ", len(urls), " URL(s) changed")
+		urls = crawler.EntryUrls(entries)
+	}
+
+	return urls, entries
+}
+
+// isIncremental returns whether only the sitemap entries changed since a
+// date or a previous run are crawled
+func isIncremental(c *cli.Context) bool {
+	return c.String("changed-since") != "" || c.String("lastmod-state") != ""
+}
+
+// changedEntries returns the entries changed since the 'changed-since' date
+// and the entries of the 'lastmod-state' file
+func changedEntries(c *cli.Context, entries []crawler.SitemapEntry) []crawler.SitemapEntry {
+	if changedSince := c.String("changed-since"); changedSince != "" {
+		since, err := crawler.ParseLastMod(changedSince)
+		if err != nil {
+			log.Fatal("Invalid changed-since date: ", err)
+		}
+		entries = crawler.ChangedSince(entries, since)
+	}
+
+	if statePath := c.String("lastmod-state"); statePath != "" {
+		state, err := crawler.LoadLastModState(statePath)
+		if err != nil {
+			log.Fatal("Failed to read lastmod state: ", err)
+		}
+		entries = state.Changed(entries)
+	}
+
+	return entries
+}
+
+// saveLastModState records the lastmod of the changed entries crawled to the
+// 'lastmod-state' file, if set
+func saveLastModState(c *cli.Context, changed []crawler.SitemapEntry, stats crawler.CrawlStats) {
+	statePath := c.String("lastmod-state")
+	if statePath == "" {
+		return
+	}
+
+	state, err := crawler.LoadLastModState(statePath)
+	if err == nil {
+		state.Record(changed, stats)
+		err = state.Save(statePath)
+	}
+	if err != nil {
+		log.Error("Failed to write lastmod state: ", err)
+	}
+}
+
+// readUrlsFile returns the URLs listed in the file, exiting if it cannot be
+// read or is empty
+func readUrlsFile(path string) []string {
+	urls, err := crawler.GetUrlsFromFile(path)
+	if err != nil {
+		log.Fatal("Failed to read URLs: ", err)
+	} else if len(urls) == 0 {
+		log.Fatal("No URL listed in ", path)
+	}
+
+	return urls
+}
+
+// warnDuplicateUrls logs the URLs listed more than once in the sitemap,
+// which are only crawled once
+func warnDuplicateUrls(loader crawler.SitemapLoader, sitemapURL string) {
+	duplicates, err := loader.FindDuplicateSitemapUrls(sitemapURL)
+	if err != nil {
+		log.Warn("Failed to check duplicate URLs: ", err)
+		return
+	}
+
+	duplicateUrls := make([]string, 0, len(duplicates))
+	for duplicateURL := range duplicates {
+		duplicateUrls = append(duplicateUrls, duplicateURL)
+	}
+	sort.Strings(duplicateUrls)
+
+	for _, duplicateURL := range duplicateUrls {
+		log.Warn("Duplicate URL in sitemap: ", duplicateURL, " listed ", duplicates[duplicateURL], " times")
+	}
+}
+
+// warnSitemapMetadata logs the sitemap metadata search engines would ignore
+func warnSitemapMetadata(loader crawler.SitemapLoader, sitemapURL string) {
+	warnings, err := loader.ValidateSitemap(sitemapURL)
+	if err != nil {
+		log.Warn("Failed to validate sitemap: ", err)
+		return
+	}
+
+	for _, warning := range warnings {
+		log.Warn("Invalid sitemap metadata: ", warning)
+	}
+}
+
+func parseHeaders(headers []string) map[string]string {
+	parsed := make(map[string]string)
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			log.Fatal("Invalid header '", header, "', expected 'Name: value'")
+		}
+		parsed[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return parsed
+}
+
+func parseHostAuth(credentials []string, tokens []string) map[string]crawler.HostAuth {
+	parsed := make(map[string]crawler.HostAuth)
+	for _, credential := range credentials {
+		parts := strings.SplitN(credential, "=", 2)
+		if len(parts) != 2 || !strings.Contains(parts[1], ":") {
+			log.Fatal("Invalid host credentials '", credential, "', expected 'host=user:pass'")
+		}
+		userPass := strings.SplitN(parts[1], ":", 2)
+		auth := parsed[parts[0]]
+		auth.User, auth.Pass = userPass[0], userPass[1]
+		parsed[parts[0]] = auth
+	}
+
+	for _, token := range tokens {
+		parts := strings.SplitN(token, "=", 2)
+		if len(parts) != 2 {
+			log.Fatal("Invalid host bearer token '", token, "', expected 'host=token'")
+		}
+		auth := parsed[parts[0]]
+		auth.BearerToken = parts[1]
+		parsed[parts[0]] = auth
+	}
+
+	return parsed
+}
+
+func parseHostResolve(overrides []string) map[string]string {
+	parsed := make(map[string]string)
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatal("Invalid host address '", override, "', expected 'host[:port]=ip[:port]'")
+		}
+		parsed[parts[0]] = parts[1]
+	}
+
+	return parsed
+}
+
+func parseCookies(cookies []string) (parsed []*http.Cookie) {
+	for _, cookie := range cookies {
+		parts := strings.SplitN(cookie, "=", 2)
+		if len(parts) != 2 {
+			log.Fatal("Invalid cookie '", cookie, "', expected 'name=value'")
+		}
+		parsed = append(parsed, &http.Cookie{Name: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])})
+	}
+
+	return parsed
+}
+
+func parseForm(fields []string) url.Values {
+	parsed := make(url.Values)
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			log.Fatal("Invalid form field '", field, "', expected 'name=value'")
+		}
+		parsed.Add(parts[0], parts[1])
+	}
+
+	return parsed
+}
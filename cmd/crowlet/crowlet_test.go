@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCancelOnSignal(t *testing.T) {
+	osSignal := make(chan os.Signal, 1)
+	ctx := cancelOnSignal(context.Background(), osSignal)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("Expected the context not to be cancelled before a signal")
+	default:
+	}
+
+	osSignal <- os.Interrupt
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the context to be cancelled by the signal")
+	}
+}
@@ -4,8 +4,10 @@ go 1.14
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/prometheus/client_golang v1.14.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/tcnksm/go-httpstat v0.1.1-0.20170410140047-fae40520f4ba
 	github.com/urfave/cli v1.22.4
 	github.com/yterajima/go-sitemap v0.2.2
+	golang.org/x/time v0.3.0
 )
@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is the time requests to a failing host are paused
+// for when CrawlConfig.BreakerCooldown is not set
+const DefaultBreakerCooldown = 30 * time.Second
+
+// circuitBreaker pauses the requests to hosts failing repeatedly, a request
+// then probing the host once the cooldown is over
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	logger    Logger
+	mutex     sync.Mutex
+	hosts     map[string]*hostCircuit
+}
+
+// hostCircuit holds the state of the circuit of a host. The circuit is open
+// until openUntil, and half-open while probing. changed is closed when the
+// state changes, to wake up the requests waiting for it.
+type hostCircuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+	changed   chan struct{}
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, logger Logger) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		logger:    logger,
+		hosts:     make(map[string]*hostCircuit),
+	}
+}
+
+// circuit returns the circuit of the host, created closed if unknown. The
+// breaker mutex must be held.
+func (breaker *circuitBreaker) circuit(host string) *hostCircuit {
+	circuit, found := breaker.hosts[host]
+	if !found {
+		circuit = &hostCircuit{changed: make(chan struct{})}
+		breaker.hosts[host] = circuit
+	}
+
+	return circuit
+}
+
+// notify wakes up the requests waiting for the circuit to change
+func (circuit *hostCircuit) notify() {
+	close(circuit.changed)
+	circuit.changed = make(chan struct{})
+}
+
+// wait blocks while the circuit of the URL host is open, or while another
+// request probes it, or until quit is closed
+func (breaker *circuitBreaker) wait(url string, quit <-chan struct{}) {
+	if breaker == nil {
+		return
+	}
+
+	host := hostOf(url)
+	for {
+		breaker.mutex.Lock()
+		circuit := breaker.circuit(host)
+		if circuit.openUntil.IsZero() {
+			breaker.mutex.Unlock()
+			return
+		}
+
+		var timeout <-chan time.Time
+		if remaining := time.Until(circuit.openUntil); remaining > 0 {
+			timeout = time.After(remaining)
+		} else if !circuit.probing {
+			// The cooldown is over, this request probes the host
+			circuit.probing = true
+			breaker.mutex.Unlock()
+			return
+		}
+		changed := circuit.changed
+		breaker.mutex.Unlock()
+
+		select {
+		case <-quit:
+			return
+		case <-timeout:
+		case <-changed:
+		}
+	}
+}
+
+// record updates the circuit of the URL host with the response. Network
+// errors and 5xx responses are failures, closing the circuit on success.
+func (breaker *circuitBreaker) record(url string, response *HTTPResponse) {
+	if breaker == nil {
+		return
+	}
+
+	host := hostOf(url)
+	failed := response.StatusCode == 0 || response.StatusCode >= 500
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	circuit := breaker.circuit(host)
+	if !failed {
+		if !circuit.openUntil.IsZero() {
+			breaker.logger.Info("Resuming requests to "+host, Fields{"host": host})
+			circuit.openUntil = time.Time{}
+			circuit.probing = false
+			circuit.notify()
+		}
+		circuit.failures = 0
+		return
+	}
+
+	circuit.failures++
+	if circuit.probing || (circuit.openUntil.IsZero() && circuit.failures >= breaker.threshold) {
+		breaker.logger.Warn(fmt.Sprintf("Pausing requests to %s for %v after %d consecutive failures",
+			host, breaker.cooldown, circuit.failures), Fields{"host": host, "failures": circuit.failures})
+		circuit.openUntil = time.Now().Add(breaker.cooldown)
+		circuit.probing = false
+		circuit.notify()
+	}
+}
@@ -0,0 +1,64 @@
+package crawler
+
+import "strings"
+
+// CacheStats holds the number of responses served from cache or not, per the
+// cache status header of HTTPConfig. Statuses counts each distinct header
+// value, and responses without the header are not counted.
+type CacheStats struct {
+	Hits     int
+	Misses   int
+	Other    int
+	Statuses map[string]int
+}
+
+// HitRatio returns the share of hits among the responses with a cache
+// status, or 0 if there is none
+func (stats CacheStats) HitRatio() float64 {
+	total := stats.Hits + stats.Misses + stats.Other
+	if total == 0 {
+		return 0
+	}
+
+	return float64(stats.Hits) / float64(total)
+}
+
+// record counts the cache status of a response. Values containing HIT, like
+// the TCP_HIT or "Hit from cloudfront" variants, are hits, and values
+// otherwise containing MISS are misses.
+func (stats *CacheStats) record(cacheStatus string) {
+	if cacheStatus == "" {
+		return
+	}
+
+	if stats.Statuses == nil {
+		stats.Statuses = make(map[string]int)
+	}
+	stats.Statuses[cacheStatus]++
+
+	upper := strings.ToUpper(cacheStatus)
+	if strings.Contains(upper, "HIT") {
+		stats.Hits++
+	} else if strings.Contains(upper, "MISS") {
+		stats.Misses++
+	} else {
+		stats.Other++
+	}
+}
+
+func mergeCacheStats(statsA, statsB CacheStats) (stats CacheStats) {
+	stats.Hits = statsA.Hits + statsB.Hits
+	stats.Misses = statsA.Misses + statsB.Misses
+	stats.Other = statsA.Other + statsB.Other
+
+	for _, statuses := range []map[string]int{statsA.Statuses, statsB.Statuses} {
+		for status, count := range statuses {
+			if stats.Statuses == nil {
+				stats.Statuses = make(map[string]int)
+			}
+			stats.Statuses[status] += count
+		}
+	}
+
+	return
+}
@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkpoint records crawl results to a file as they arrive, one JSON object
+// per line, and holds the results of the checkpoint a crawl resumes from
+type checkpoint struct {
+	recorded map[string]CrawlResult
+	logger   Logger
+	file     *os.File
+	encoder  *json.Encoder
+	failed   bool
+}
+
+// newCheckpoint loads the results to resume from, and opens the checkpoint
+// file. Resuming to the same checkpoint file appends to it, while the file
+// is otherwise truncated and starts with the resumed results. It returns nil
+// if neither ResumeFrom nor Checkpoint are set.
+func newCheckpoint(config CrawlConfig) (*checkpoint, error) {
+	if config.ResumeFrom == "" && config.Checkpoint == "" {
+		return nil, nil
+	}
+
+	recorded := make(map[string]CrawlResult)
+	if config.ResumeFrom != "" {
+		results, err := loadCheckpoint(config.ResumeFrom, config.logger())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			recorded[result.URL] = result
+		}
+		config.logger().Info(fmt.Sprintf("Resuming crawl with %d URL(s) already crawled", len(recorded)),
+			Fields{"checkpoint": config.ResumeFrom})
+	}
+
+	cp := &checkpoint{recorded: recorded, logger: config.logger()}
+	if config.Checkpoint == "" {
+		return cp, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	resumingToSameFile := config.Checkpoint == config.ResumeFrom
+	if resumingToSameFile {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(config.Checkpoint, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.file = file
+	cp.encoder = json.NewEncoder(file)
+	if !resumingToSameFile {
+		for _, result := range recorded {
+			cp.record(result)
+		}
+	}
+
+	return cp, nil
+}
+
+// loadCheckpoint returns the results recorded in a checkpoint file. A missing
+// file holds no results, and a truncated last line is ignored.
+func loadCheckpoint(path string, logger Logger) (results []CrawlResult, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var result CrawlResult
+		err := decoder.Decode(&result)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			logger.Warn(fmt.Sprintf("Ignoring end of checkpoint %s: %v", path, err), Fields{"checkpoint": path})
+			break
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// pending splits the URLs between the ones to crawl, and the results of the
+// ones already crawled
+func (cp *checkpoint) pending(urls []string) ([]string, []CrawlResult) {
+	if cp == nil || len(cp.recorded) == 0 {
+		return urls, nil
+	}
+
+	var pending []string
+	var recorded []CrawlResult
+	for _, url := range urls {
+		if result, found := cp.recorded[url]; found {
+			recorded = append(recorded, result)
+		} else {
+			pending = append(pending, url)
+		}
+	}
+
+	return pending, recorded
+}
+
+// record appends the result to the checkpoint file, if any
+func (cp *checkpoint) record(result CrawlResult) {
+	if cp == nil || cp.encoder == nil || cp.failed {
+		return
+	}
+
+	err := cp.encoder.Encode(result)
+	if err != nil {
+		cp.logger.Error(fmt.Sprintf("Failed to write checkpoint: %v", err), nil)
+		cp.failed = true
+	}
+}
+
+func (cp *checkpoint) close() {
+	if cp == nil || cp.file == nil {
+		return
+	}
+
+	cp.file.Close()
+}
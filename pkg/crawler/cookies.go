@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// LoginConfig holds a login form submitted before crawling, to establish a
+// session whose cookies are then sent with every request. Method is POST if
+// empty, or GET to send the form in the query string. The login fails if its
+// response has an error status, or does not set ExpectedCookie if not empty.
+type LoginConfig struct {
+	URL            string
+	Method         string
+	Form           url.Values
+	ExpectedCookie string
+}
+
+// newLoginRequest returns the request submitting the login form
+func newLoginRequest(config LoginConfig) (*http.Request, error) {
+	if config.Method == http.MethodGet {
+		loginURL, err := url.Parse(config.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		query := loginURL.Query()
+		for name, values := range config.Form {
+			query[name] = append(query[name], values...)
+		}
+		loginURL.RawQuery = query.Encode()
+
+		return http.NewRequest(http.MethodGet, loginURL.String(), nil)
+	}
+
+	method := config.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, config.URL, strings.NewReader(config.Form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return req, nil
+}
+
+// newCookieJar returns the jar shared by the requests of a crawl, seeded with
+// the configured cookies. Cookies without a domain are set for the hosts of
+// the URLs passed, while cookies with a domain only for that domain.
+func newCookieJar(config HTTPConfig, urls []string) (http.CookieJar, error) {
+	jar := config.Jar
+	if jar == nil {
+		var err error
+		jar, err = cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hosts := make(map[string]*url.URL)
+	for _, rawURL := range urls {
+		target, err := url.Parse(rawURL)
+		if err != nil || target.Host == "" {
+			continue
+		}
+		hosts[target.Scheme+"://"+target.Host] = &url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/"}
+	}
+
+	for _, cookie := range config.Cookies {
+		if cookie.Domain != "" {
+			domain := strings.TrimPrefix(cookie.Domain, ".")
+			jar.SetCookies(&url.URL{Scheme: "https", Host: domain, Path: "/"}, []*http.Cookie{cookie})
+			continue
+		}
+
+		for _, host := range hosts {
+			jar.SetCookies(host, []*http.Cookie{cookie})
+		}
+	}
+
+	return jar, nil
+}
+
+// login submits the login form, storing the session cookies in the jar of
+// the configuration
+func login(config HTTPConfig) error {
+	req, err := newLoginRequest(*config.Login)
+	if err != nil {
+		return err
+	}
+
+	configureRequest(req, config)
+
+	client, release, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Records the cookies set along redirects following the login
+	jar := &recordingJar{CookieJar: client.Jar, names: make(map[string]bool)}
+	client.Jar = jar
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login failed with status code %d", resp.StatusCode)
+	}
+
+	if expected := config.Login.ExpectedCookie; expected != "" && !jar.names[expected] {
+		return fmt.Errorf("login failed, no '%s' cookie was set", expected)
+	}
+
+	config.logger().Info("Logged in at "+config.Login.URL, Fields{"url": config.Login.URL})
+	return nil
+}
+
+// recordingJar records the names of the cookies set through it
+type recordingJar struct {
+	http.CookieJar
+	names map[string]bool
+}
+
+func (jar *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		jar.names[cookie.Name] = true
+	}
+	jar.CookieJar.SetCookies(u, cookies)
+}
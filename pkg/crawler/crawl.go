@@ -1,11 +1,17 @@
 package crawler
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/yterajima/go-sitemap"
 )
 
@@ -15,38 +21,394 @@ type CrawlResult struct {
 	StatusCode  int           `json:"status-code"`
 	Time        time.Duration `json:"server-time"`
 	LinkingURLs []string      `json:"linking-urls"`
+	Attempts    int           `json:"attempts"`
+	Error       string        `json:"error,omitempty"`
+	ErrorKind   ErrorKind     `json:"error-kind,omitempty"`
+	// RetryAfter is the time waited before retries as requested by the
+	// Retry-After header of 429 and 503 responses
+	RetryAfter time.Duration `json:"retry-after,omitempty"`
+	// FinalURL and RedirectChain are only set if the URL redirected
+	FinalURL      string `json:"final-url,omitempty"`
+	RedirectChain []int  `json:"redirect-chain,omitempty"`
+	// Depth is the number of link hops from the sitemap URLs
+	Depth int `json:"depth"`
+	// ContentType is the Content-Type header of the response, while
+	// ExpectedContentType is only set if a content type check applies
+	ContentType         string `json:"content-type,omitempty"`
+	ExpectedContentType string `json:"expected-content-type,omitempty"`
+	// BodySize is the number of body bytes read, possibly truncated
+	BodySize int64 `json:"body-size,omitempty"`
+	// Headers holds the captured response headers
+	Headers map[string]string `json:"headers,omitempty"`
+	// Soft404Match is the soft 404 pattern found in a 200 response, if any
+	Soft404Match string `json:"soft-404-match,omitempty"`
+	// CacheStatus is the value of the cache status header, if configured
+	CacheStatus string `json:"cache-status,omitempty"`
+	// AddressFamily is "ipv4" or "ipv6", the family of the address the
+	// response was received from
+	AddressFamily string `json:"address-family,omitempty"`
+	// Phases holds the duration of the phases of the request, if sent
+	Phases PhaseTimes `json:"phases"`
 }
 
-// CrawlStats holds crawling related information: status codes, time
-// and totals
+// PhaseTimes holds the duration of the phases of a request. DNS, Connect and
+// TLS are 0 when a kept-alive connection is reused, and TTFB is the time from
+// the start of the request to the first response byte.
+type PhaseTimes struct {
+	DNS     time.Duration `json:"dns"`
+	Connect time.Duration `json:"connect"`
+	TLS     time.Duration `json:"tls"`
+	TTFB    time.Duration `json:"ttfb"`
+}
+
+// hasExpectedContentType returns whether the media type of the result starts
+// with its expected content type, if any
+func (result CrawlResult) hasExpectedContentType() bool {
+	if result.ExpectedContentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(result.ContentType)
+	if err != nil {
+		mediaType = result.ContentType
+	}
+
+	return strings.HasPrefix(mediaType, result.ExpectedContentType)
+}
+
+// CrawlStats holds crawling related information: status codes, time and
+// totals. Times200 holds the server time of every successful response, 200
+// unless SuccessCodes is configured, in completion order, and Successes their
+// number. Redirects holds the 3xx responses but 304, and Non200Urls the other
+// responses, both sorted by URL then status code once the crawl is done or
+// merged. Redirects are not failures unless FailOn says so.
+// ContentTypeMismatches holds the 200 responses without their expected content
+// type, and SlowUrls the successful responses slower than the configured slow
+// threshold, and Soft404Urls the 200 responses matching a soft 404 pattern.
+// RedirectedUrls holds the sitemap URLs answering successfully after
+// redirecting, which should list their final URL instead. MixedContent holds
+// the http subresources referenced from https pages, if detected, and
+// BrokenFragments the links to missing fragments, if checked. ParseErrors
+// holds the errors met parsing pages for links, like unparseable link URLs,
+// sorted by page URL. They are not failures. Cache counts the responses served
+// from cache or not, if a cache status header is configured. Results holds
+// every crawled URL, in completion order. TotalBytes is the sum of the body
+// sizes read, at most MaxBodyBytes each. NotModified counts the 304 responses
+// to conditional requests, which are healthy and neither redirects nor errors.
+// ErrorKinds counts the results of each ErrorKind, successful ones excepted.
+// WallClock is the time spent crawling URLs, summed over merged crawls.
+// Stopped is set if the crawl was interrupted or reached its maximum duration
+// or failures, the statistics only covering the URLs crawled so far.
 type CrawlStats struct {
-	Total          int
-	StatusCodes    map[int]int
-	Average200Time time.Duration
-	Max200Time     time.Duration
-	Non200Urls     []CrawlResult
+	Total                 int
+	TotalBytes            int64
+	NotModified           int
+	ErrorKinds            map[ErrorKind]int
+	WallClock             time.Duration
+	Stopped               bool
+	StatusCodes           map[int]int
+	Average200Time        time.Duration
+	Max200Time            time.Duration
+	Times200              []time.Duration
+	Successes             int
+	Non200Urls            []CrawlResult
+	Redirects             []CrawlResult
+	ContentTypeMismatches []CrawlResult
+	SlowUrls              []CrawlResult
+	Soft404Urls           []CrawlResult
+	RedirectedUrls        []CrawlResult
+	MixedContent          []MixedContent
+	BrokenFragments       []BrokenFragment
+	ParseErrors           []ParseError
+	Cache                 CacheStats
+	Results               []CrawlResult
+	// EmptyBodyUrls holds the 200 responses to GET requests without any body
+	// byte, if FailOnEmptyBody is set
+	EmptyBodyUrls []CrawlResult
+	// ByHost holds the statistics of each crawled host, nil for per host
+	// statistics themselves
+	ByHost map[string]CrawlStats
+
+	// successCodes are the SuccessCodes of the crawl, 200 if nil
+	successCodes map[int]bool
+}
+
+// Average200Phases returns the average duration of each request phase over
+// the successful responses, 200 unless SuccessCodes is configured, or zero
+// durations if no successful response was received
+func (stats CrawlStats) Average200Phases() (average PhaseTimes) {
+	successes := CrawlConfig{SuccessCodes: stats.successCodes}
+	var count time.Duration
+	for _, result := range stats.Results {
+		if !successes.isSuccess(result.StatusCode) {
+			continue
+		}
+
+		count++
+		average.DNS += result.Phases.DNS
+		average.Connect += result.Phases.Connect
+		average.TLS += result.Phases.TLS
+		average.TTFB += result.Phases.TTFB
+	}
+
+	if count == 0 {
+		return PhaseTimes{}
+	}
+
+	average.DNS /= count
+	average.Connect /= count
+	average.TLS /= count
+	average.TTFB /= count
+	return average
 }
 
-// CrawlConfig holds crawling configuration.
+// TopSlowest returns the n slowest crawled URLs whatever their status code,
+// slowest first. URLs without response are left out, their time not being
+// the server's.
+func (stats CrawlStats) TopSlowest(n int) []CrawlResult {
+	var slowest []CrawlResult
+	for _, result := range stats.Results {
+		if result.StatusCode != 0 {
+			slowest = append(slowest, result)
+		}
+	}
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Time > slowest[j].Time
+	})
+
+	if n < 0 {
+		n = 0
+	}
+	if n < len(slowest) {
+		slowest = slowest[:n]
+	}
+
+	return slowest
+}
+
+// Percentile200Time returns the server time under which the given percentage
+// of 200 responses were received, using the nearest-rank method. Returns 0
+// if no 200 response was received.
+func (stats CrawlStats) Percentile200Time(percentile float64) time.Duration {
+	if len(stats.Times200) == 0 {
+		return 0
+	}
+
+	times := make([]time.Duration, len(stats.Times200))
+	copy(times, stats.Times200)
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	rank := int(math.Ceil(percentile / 100 * float64(len(times))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(times) {
+		rank = len(times)
+	}
+
+	return times[rank-1]
+}
+
+// CrawlConfig holds crawling configuration
 type CrawlConfig struct {
-	Throttle   int
-	Host       string
-	HTTP       HTTPConfig
-	Links      CrawlLinksConfig
-	HTTPGetter ConcurrentHTTPGetter
+	// Throttle is the maximum number of concurrent requests, and
+	// PerHostThrottle the maximum to a single host if set
+	Throttle        int
+	PerHostThrottle int
+	// MaxRPS caps the number of requests per second, unlimited if 0
+	MaxRPS float64
+	// RampUp staggers the start of the first Throttle requests over that
+	// duration, independently of MaxRPS
+	RampUp time.Duration
+	// Workers is the number of URLs handled at once, waiting for robots.txt
+	// delays or MaxRPS before their request is sent, Throttle if 0. More
+	// workers than Throttle keep requests flowing to other hosts while some
+	// URLs wait, at most Throttle requests being sent at once.
+	Workers int
+	// BreakerFailures pauses the requests to a host for BreakerCooldown, or
+	// DefaultBreakerCooldown if 0, after that many consecutive network errors
+	// or 5xx responses, disabled if 0. A single request then probes the host,
+	// the others resuming if it succeeds.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	Scheme          string
+	Host            string
+	HTTP            HTTPConfig
+	Links           CrawlLinksConfig
+	HTTPGetter      ConcurrentHTTPGetter
+	Metrics         *CrawlMetrics
+	// RespectRobotsTxt skips the linked URLs disallowed by their host's
+	// robots.txt and honors its crawl delay. Sitemap URLs are exempt unless
+	// RobotsTxtSitemapUrls is also set.
+	RespectRobotsTxt     bool
+	RobotsTxtSitemapUrls bool
+	// IncludePatterns and ExcludePatterns are regular expressions selecting
+	// the URLs to crawl, exclusion winning over inclusion
+	IncludePatterns []string
+	ExcludePatterns []string
+	// FailOn is the policy deciding which status codes make AsyncCrawl return
+	// an error, DefaultFailOn if nil
+	FailOn StatusPolicy
+	// SuccessCodes are the status codes of successful responses, like 201 or
+	// 204 for APIs, which are part of the time statistics and not failures
+	// unless FailOn says so, only 200 if nil
+	SuccessCodes map[int]bool
+	// DedupeUrls crawls URLs listed several times only once
+	DedupeUrls bool
+	// SampleN limits the crawl to a random sample of the URLs remaining after
+	// filtering, picked using SampleSeed for reproducible samples, or a random
+	// seed if 0. MaxURLs then limits the crawl to the first of these URLs,
+	// unlimited if 0. Linked URLs are neither sampled nor limited.
+	SampleN    int
+	SampleSeed int64
+	MaxURLs    int
+	DryRun     bool
+	// SlowThreshold reports the successful responses slower than it as slow
+	// if set, and AsyncCrawl returns an error if at least FailOnSlowUrls URLs
+	// are slow, unless 0
+	SlowThreshold  time.Duration
+	FailOnSlowUrls int
+	// Checkpoint is a file where results are recorded as they arrive, and
+	// ResumeFrom a checkpoint whose URLs are not crawled again, their recorded
+	// results being reported instead. Links of resumed pages are not
+	// followed.
+	Checkpoint string
+	ResumeFrom string
+	// MaxCrawlDuration stops the crawl like an interrupt once exceeded,
+	// unlimited if 0, in which case partial statistics are returned.
+	// MaxFailures similarly stops the crawl once that many URLs failed
+	// following FailOn, unlimited if 0.
+	MaxCrawlDuration time.Duration
+	MaxFailures      int
+	// GracePeriod is given to in-flight requests to finish and be reported
+	// once the crawl is stopped, no new request being sent. They are aborted
+	// right away if 0.
+	GracePeriod time.Duration
+	// DetectMixedContent reports the http images, stylesheets and scripts
+	// referenced from internal https pages, making AsyncCrawl return an error
+	DetectMixedContent bool
+	// FailOnRedirects makes AsyncCrawl return an error if sitemap URLs
+	// redirected
+	FailOnRedirects bool
+	// Normalize returns the canonical form of a URL, applied to sitemap URLs
+	// before they are deduplicated and to linked URLs before they are
+	// followed, so that equivalent URLs are crawled once. Normalizers should
+	// return a modified copy rather than modify their argument, like
+	// StripFragment and StripUTMParams.
+	Normalize func(*url.URL) *url.URL
+	// PreserveOrder reports the results in the order of the URLs, as listed
+	// by the sitemap and then as linked, buffering the requests completed
+	// early. Requests are still sent concurrently. Resumed results are
+	// reported first.
+	PreserveOrder bool
+	// DeadLetterWriter receives the URLs failing following FailOn as they
+	// arrive, as one CrawlResult JSON object per line including their status,
+	// error and linking pages, for a later job to retry them. Resumed results
+	// are not written again.
+	DeadLetterWriter io.Writer
+	// WarmUp sends a throwaway request to the first URL of each host before
+	// the crawl, so that cold start penalties do not skew its statistics. The
+	// warm-up requests are not counted, nor part of WallClock.
+	WarmUp bool
+	// Window is the time window allowed for the crawl, if set. The crawl
+	// waits for it to start, and is paused outside of it, requests in flight
+	// being completed. The pauses count in MaxCrawlDuration.
+	Window *TimeWindow
+	// FailOnEmptyBody reports the 200 responses to GET requests without any
+	// body byte, which are usually broken templates, making AsyncCrawl
+	// return an error. Bodies discarded by DiscardBody are not checked.
+	FailOnEmptyBody bool
+	// OnResult is called for each crawled URL as results arrive, from the
+	// goroutine running AsyncCrawl
+	OnResult func(CrawlResult, Progress)
+	// Logger receives the logs of the crawl, the default logrus logger if nil,
+	// and LogLevel is the minimum level of the logs passed to it and to the
+	// HTTP Logger. Summaries are not logs, and are printed whatever the level.
+	Logger   Logger
+	LogLevel LogLevel
+	// Formatter replaces the logging of each crawled URL if set, its output
+	// being written to FormatterOutput, or stdout if nil. Its summary is
+	// written once the crawl is done.
+	Formatter       ResultFormatter
+	FormatterOutput io.Writer
+
+	progress     *progressTracker
+	checkpoint   *checkpoint
+	failureLimit *failureLimit
+	deadLetters  *deadLetters
+}
+
+// Progress holds the number of crawled URLs, and the number of URLs to crawl
+// known so far. Total grows as linked URLs are discovered.
+type Progress struct {
+	Completed int
+	Total     int
 }
 
-// CrawlLinksConfig holds the crawling policy for links
+type progressTracker struct {
+	progress Progress
+}
+
+func (tracker *progressTracker) add(total int) {
+	if tracker != nil {
+		tracker.progress.Total += total
+	}
+}
+
+func (tracker *progressTracker) complete() Progress {
+	if tracker == nil {
+		return Progress{}
+	}
+
+	tracker.progress.Completed++
+	return tracker.progress
+}
+
+// CrawlLinksConfig holds the crawling policy for links. MaxLinkDepth is the
+// maximum number of hops followed from the sitemap URLs, defaulting to 1.
+// ExpectedContentTypes holds the content type prefix expected for 200
+// responses of each link type, sitemap URLs being checked as hyperlinks.
+// NormalizeURLs drops the fragment and sorts the query parameters of linked
+// URLs, so that variants of the same URL are crawled once. CheckFragments
+// reports the hyperlinks of internal pages whose fragment is neither an
+// element id nor an anchor name of their crawled target page, making
+// AsyncCrawl return an error. LinkPrefixAllow limits the linked URLs crawled
+// to the ones whose path starts with one of its prefixes, like "/docs/", any
+// path being crawled if empty. Sitemap URLs are not filtered.
 type CrawlLinksConfig struct {
-	CrawlExternalLinks bool
-	CrawlHyperlinks    bool
-	CrawlImages        bool
+	CrawlExternalLinks   bool
+	CrawlHyperlinks      bool
+	CrawlImages          bool
+	CrawlAlternateLinks  bool
+	CrawlStylesheets     bool
+	CrawlScripts         bool
+	MaxLinkDepth         int
+	NormalizeURLs        bool
+	CheckFragments       bool
+	LinkPrefixAllow      []string
+	ExpectedContentTypes map[LinkType]string
+}
+
+// DefaultExpectedContentTypes expects hyperlinks to be HTML pages, and images
+// to have an image content type
+var DefaultExpectedContentTypes = map[LinkType]string{
+	Hyperlink: "text/html",
+	Image:     "image/",
 }
 
 // MergeCrawlStats merges two sets of crawling statistics together.
 func MergeCrawlStats(statsA, statsB CrawlStats) (stats CrawlStats) {
 	stats.StatusCodes = make(map[int]int)
 	stats.Total = statsA.Total + statsB.Total
+	stats.TotalBytes = statsA.TotalBytes + statsB.TotalBytes
+	stats.NotModified = statsA.NotModified + statsB.NotModified
+	stats.ErrorKinds = mergeErrorKinds(statsA.ErrorKinds, statsB.ErrorKinds)
+	stats.WallClock = statsA.WallClock + statsB.WallClock
+	stats.Stopped = statsA.Stopped || statsB.Stopped
+
+	stats.successCodes = statsA.successCodes
+	if stats.successCodes == nil {
+		stats.successCodes = statsB.successCodes
+	}
 
 	if statsA.Max200Time > statsB.Max200Time {
 		stats.Max200Time = statsA.Max200Time
@@ -65,38 +427,124 @@ func MergeCrawlStats(statsA, statsB CrawlStats) (stats CrawlStats) {
 		}
 	}
 
-	if statsA.Average200Time != 0 || statsB.Average200Time != 0 {
-		total200ns := (statsA.Average200Time.Nanoseconds()*int64(statsA.StatusCodes[200]) +
-			statsB.Average200Time.Nanoseconds()*int64(statsB.StatusCodes[200]))
-		stats.Average200Time = time.Duration(total200ns/int64(stats.StatusCodes[200])) * time.Nanosecond
+	// Averages are weighted by their number of successful responses, an
+	// average without successful responses being ignored
+	stats.Successes = statsA.Successes + statsB.Successes
+	if stats.Successes > 0 {
+		total200ns := (statsA.Average200Time.Nanoseconds()*int64(statsA.Successes) +
+			statsB.Average200Time.Nanoseconds()*int64(statsB.Successes))
+		stats.Average200Time = time.Duration(total200ns/int64(stats.Successes)) * time.Nanosecond
 	}
 
+	stats.Times200 = append(stats.Times200, statsA.Times200...)
+	stats.Times200 = append(stats.Times200, statsB.Times200...)
+
 	stats.Non200Urls = append(stats.Non200Urls, statsA.Non200Urls...)
 	stats.Non200Urls = append(stats.Non200Urls, statsB.Non200Urls...)
+	sortResults(stats.Non200Urls)
+
+	stats.Redirects = append(stats.Redirects, statsA.Redirects...)
+	stats.Redirects = append(stats.Redirects, statsB.Redirects...)
+	sortResults(stats.Redirects)
+
+	stats.ContentTypeMismatches = append(stats.ContentTypeMismatches, statsA.ContentTypeMismatches...)
+	stats.ContentTypeMismatches = append(stats.ContentTypeMismatches, statsB.ContentTypeMismatches...)
+
+	stats.SlowUrls = append(stats.SlowUrls, statsA.SlowUrls...)
+	stats.SlowUrls = append(stats.SlowUrls, statsB.SlowUrls...)
+
+	stats.Soft404Urls = append(stats.Soft404Urls, statsA.Soft404Urls...)
+	stats.Soft404Urls = append(stats.Soft404Urls, statsB.Soft404Urls...)
+
+	stats.RedirectedUrls = append(stats.RedirectedUrls, statsA.RedirectedUrls...)
+	stats.RedirectedUrls = append(stats.RedirectedUrls, statsB.RedirectedUrls...)
+
+	stats.EmptyBodyUrls = append(stats.EmptyBodyUrls, statsA.EmptyBodyUrls...)
+	stats.EmptyBodyUrls = append(stats.EmptyBodyUrls, statsB.EmptyBodyUrls...)
+	sortResults(stats.EmptyBodyUrls)
+
+	stats.MixedContent = append(stats.MixedContent, statsA.MixedContent...)
+	stats.MixedContent = append(stats.MixedContent, statsB.MixedContent...)
+
+	stats.BrokenFragments = append(stats.BrokenFragments, statsA.BrokenFragments...)
+	stats.BrokenFragments = append(stats.BrokenFragments, statsB.BrokenFragments...)
+
+	stats.ParseErrors = append(stats.ParseErrors, statsA.ParseErrors...)
+	stats.ParseErrors = append(stats.ParseErrors, statsB.ParseErrors...)
+	sortParseErrors(stats.ParseErrors)
+
+	stats.Cache = mergeCacheStats(statsA.Cache, statsB.Cache)
+
+	stats.Results = append(stats.Results, statsA.Results...)
+	stats.Results = append(stats.Results, statsB.Results...)
+
+	stats.ByHost = mergeHostStats(statsA.ByHost, statsB.ByHost)
 
 	return
 }
 
-// GetSitemapUrls returns all URLs found from the sitemap passed as parameter.
+// clone returns a copy of the statistics sharing none of their maps and
+// slices, the results themselves being shared
+func (stats CrawlStats) clone() CrawlStats {
+	clone := stats
+	clone.ErrorKinds = mergeErrorKinds(stats.ErrorKinds, nil)
+	if stats.StatusCodes != nil {
+		clone.StatusCodes = make(map[int]int, len(stats.StatusCodes))
+		for statusCode, count := range stats.StatusCodes {
+			clone.StatusCodes[statusCode] = count
+		}
+	}
+	clone.Times200 = append([]time.Duration(nil), stats.Times200...)
+	clone.Non200Urls = append([]CrawlResult(nil), stats.Non200Urls...)
+	clone.Redirects = append([]CrawlResult(nil), stats.Redirects...)
+	clone.ContentTypeMismatches = append([]CrawlResult(nil), stats.ContentTypeMismatches...)
+	clone.SlowUrls = append([]CrawlResult(nil), stats.SlowUrls...)
+	clone.Soft404Urls = append([]CrawlResult(nil), stats.Soft404Urls...)
+	clone.RedirectedUrls = append([]CrawlResult(nil), stats.RedirectedUrls...)
+	clone.EmptyBodyUrls = append([]CrawlResult(nil), stats.EmptyBodyUrls...)
+	clone.MixedContent = append([]MixedContent(nil), stats.MixedContent...)
+	clone.BrokenFragments = append([]BrokenFragment(nil), stats.BrokenFragments...)
+	clone.ParseErrors = append([]ParseError(nil), stats.ParseErrors...)
+	clone.Cache = mergeCacheStats(stats.Cache, CacheStats{})
+	clone.Results = append([]CrawlResult(nil), stats.Results...)
+	if stats.ByHost != nil {
+		clone.ByHost = make(map[string]CrawlStats, len(stats.ByHost))
+		for host, hostStats := range stats.ByHost {
+			clone.ByHost[host] = hostStats.clone()
+		}
+	}
+
+	return clone
+}
+
+// GetSitemapUrls returns all URLs found from the sitemap passed as parameter,
+// which can be a URL or a local file path.
 // This function will only retrieve URLs in the sitemap pointed, and in
-// sitemaps directly listed (i.e. only 1 level deep or less)
-func GetSitemapUrls(sitemapURL string) (urls []*url.URL, err error) {
-	sitemap, err := sitemap.Get(sitemapURL, nil)
+// sitemaps directly listed (i.e. only 1 level deep or less). URL entries that
+// cannot be parsed are skipped, and returned as SitemapErrors.
+func (loader SitemapLoader) GetSitemapUrls(sitemapURL string) (urls []*url.URL, err error) {
+	sitemap, err := sitemap.Get(sitemapURL, loader.HTTP)
 
 	if err != nil {
-		log.Error(err)
+		loader.HTTP.logger().Error(err.Error(), Fields{"sitemap": sitemapURL})
 		return
 	}
 
+	var invalid SitemapErrors
 	for _, urlEntry := range sitemap.URL {
-		newURL, err := url.Parse(urlEntry.Loc)
-		if err != nil {
-			log.Error(err)
+		newURL, parseErr := url.Parse(urlEntry.Loc)
+		if parseErr != nil {
+			loader.HTTP.logger().Error(parseErr.Error(), Fields{"sitemap": sitemapURL})
+			invalid = append(invalid, SitemapError{SitemapURL: sitemapURL, Loc: urlEntry.Loc, Err: parseErr})
 			continue
 		}
 		urls = append(urls, newURL)
 	}
 
+	if len(invalid) > 0 {
+		err = invalid
+	}
+
 	return
 }
 
@@ -104,8 +552,8 @@ func GetSitemapUrls(sitemapURL string) (urls []*url.URL, err error) {
 // sitemap passed as parameter.
 // This function will only retrieve URLs in the sitemap pointed, and in
 // sitemaps directly listed (i.e. only 1 level deep or less)
-func GetSitemapUrlsAsStrings(sitemapURL string) (urls []string, err error) {
-	typedUrls, err := GetSitemapUrls(sitemapURL)
+func (loader SitemapLoader) GetSitemapUrlsAsStrings(sitemapURL string) (urls []string, err error) {
+	typedUrls, err := loader.GetSitemapUrls(sitemapURL)
 	for _, url := range typedUrls {
 		urls = append(urls, url.String())
 	}
@@ -113,50 +561,343 @@ func GetSitemapUrlsAsStrings(sitemapURL string) (urls []string, err error) {
 	return
 }
 
+// GetSitemapUrls is SitemapLoader.GetSitemapUrls with the default settings
+func GetSitemapUrls(sitemapURL string) (urls []*url.URL, err error) {
+	return SitemapLoader{}.GetSitemapUrls(sitemapURL)
+}
+
+// GetSitemapUrlsAsStrings is SitemapLoader.GetSitemapUrlsAsStrings with the
+// default settings
+func GetSitemapUrlsAsStrings(sitemapURL string) (urls []string, err error) {
+	return SitemapLoader{}.GetSitemapUrlsAsStrings(sitemapURL)
+}
+
 // AsyncCrawl crawls asynchronously URLs from a sitemap and prints related
 // information. Throttle is the maximum number of parallel HTTP requests.
-// Host overrides the hostname used in the sitemap if provided,
-// and user/pass are optional basic auth credentials
-func AsyncCrawl(urls []string, config CrawlConfig, quit <-chan struct{}) (stats CrawlStats, err error) {
+// Scheme and Host override the scheme and host used in the sitemap if
+// provided, Host accepting an optional port, and user/pass are optional basic
+// auth credentials. With DryRun, the URLs that would be crawled are only
+// logged, and no request is sent.
+func AsyncCrawl(urls []string, config CrawlConfig, quit <-chan struct{}) (CrawlStats, error) {
+	return crawl(urls, config, quit)
+}
+
+func crawl(urls []string, config CrawlConfig, quit <-chan struct{}) (stats CrawlStats, err error) {
+	if config.HTTP.Logger == nil {
+		config.HTTP.Logger = config.Logger
+	}
+	if config.LogLevel != LogDefault {
+		config.Logger = withLevel(config.logger(), config.LogLevel)
+		config.HTTP.Logger = withLevel(config.HTTP.logger(), config.LogLevel)
+	}
+
 	if config.Throttle <= 0 {
-		log.Warn("Invalid throttle value, defaulting to 1.")
+		config.logger().Warn("Invalid throttle value, defaulting to 1.", nil)
 		config.Throttle = 1
 	}
 
-	config.HTTP.ParseLinks = config.Links.CrawlExternalLinks || config.Links.CrawlHyperlinks ||
-		config.Links.CrawlImages
-	results, stats, server200TimeSum := crawlUrls(urls, config, quit)
+	err = config.HTTP.checkAuth()
+	if err != nil {
+		return
+	}
+
+	urls, err = ResolveUrls(urls, config)
+	if err != nil {
+		return
+	}
+
+	if len(config.HTTP.IfModifiedSince) > 0 && (config.Scheme != "" || config.Host != "") {
+		config.HTTP.ifModifiedSinceURIs = byRequestURI(config.HTTP.IfModifiedSince)
+	}
+
+	if config.DryRun {
+		for _, url := range urls {
+			config.logger().Info("Would crawl "+url, Fields{"url": url})
+		}
+		return
+	}
+
+	followLinks := config.Links.CrawlExternalLinks || config.Links.CrawlHyperlinks ||
+		config.Links.CrawlImages || config.Links.CrawlAlternateLinks || config.Links.CrawlStylesheets ||
+		config.Links.CrawlScripts
+	config.HTTP.ParseLinks = followLinks || config.DetectMixedContent || config.Links.CheckFragments
+	config.HTTP.checkFragments = config.Links.CheckFragments
+	config.HTTP.formatted = config.Formatter != nil || config.PreserveOrder
+
+	if config.HTTP.InsecureSkipVerify {
+		config.logger().Warn("TLS certificate verification is disabled, do not use in production", nil)
+	}
+
+	config.HTTP.transport, err = newTransport(config.HTTP)
+	if err != nil {
+		return
+	} else if config.HTTP.transport != nil {
+		defer config.HTTP.transport.CloseIdleConnections()
+	}
+
+	if config.RespectRobotsTxt {
+		config.HTTP.robots = newRobotsTxtCache(config.HTTP)
+	}
+
+	if config.PerHostThrottle > 0 {
+		config.HTTP.hostThrottle = newHostThrottle(config.PerHostThrottle)
+	}
+
+	if config.MaxRPS > 0 {
+		config.HTTP.rateLimiter = newRateLimiter(config.MaxRPS)
+	}
+
+	config.HTTP.workers = config.Workers
+
+	if config.BreakerFailures > 0 {
+		config.HTTP.breaker = newCircuitBreaker(config.BreakerFailures, config.BreakerCooldown, config.logger())
+	}
+
+	if config.RampUp > 0 {
+		config.HTTP.rampUp = newRampUp(config.RampUp, config.Throttle)
+	}
+
+	if config.HTTP.Jar != nil || len(config.HTTP.Cookies) > 0 || config.HTTP.Login != nil {
+		config.HTTP.Jar, err = newCookieJar(config.HTTP, urls)
+		if err != nil {
+			return
+		}
+
+		if config.HTTP.Login != nil {
+			err = login(config.HTTP)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	config.progress = &progressTracker{}
+
+	config.checkpoint, err = newCheckpoint(config)
+	if err != nil {
+		return
+	}
+	defer config.checkpoint.close()
+
+	config.deadLetters = newDeadLetters(config)
+
+	sitemapConfig := config
+	if config.RobotsTxtSitemapUrls {
+		urls = filterRobotsTxtDisallowed(urls, config)
+	} else {
+		sitemapConfig.HTTP.robots = nil
+	}
+
+	crawlCtx, stop := withMaxDuration(quit, config.MaxCrawlDuration)
+	defer stop()
+	quit = crawlCtx.Done()
+
+	if config.GracePeriod > 0 {
+		abortCtx, abort := withGracePeriod(quit, config.GracePeriod, config.logger())
+		defer abort()
+		config.HTTP.abort = abortCtx.Done()
+		sitemapConfig.HTTP.abort = config.HTTP.abort
+	}
+
+	if config.MaxFailures > 0 {
+		config.failureLimit = newFailureLimit(config.MaxFailures, config.FailurePolicy(), stop)
+		sitemapConfig.failureLimit = config.failureLimit
+	}
+
+	if config.Window != nil {
+		config.HTTP.window = newWindowGuard(*config.Window, config.logger())
+		sitemapConfig.HTTP.window = config.HTTP.window
+		config.HTTP.window.wait(quit)
+
+		select {
+		case <-quit:
+			// Stopped before the window opened, nothing is crawled
+			urls = nil
+		default:
+		}
+	}
+
+	if config.WarmUp {
+		warmUp(urls, sitemapConfig, quit)
+	}
+
+	start := time.Now()
+	results, stats, server200TimeSum := crawlUrls(urls, urlSources{}, sitemapConfig, quit)
 
 	select {
 	case <-quit:
 		break
 	default:
-		if config.HTTP.ParseLinks {
-			_, linksStats, linksServer200TimeSum := crawlLinks(results, urls, config, quit)
+		if followLinks {
+			linksResults, linksStats, linksServer200TimeSum := crawlLinks(results, urls, config, quit)
 			stats = MergeCrawlStats(stats, linksStats)
 			server200TimeSum += linksServer200TimeSum
+			results = append(results, linksResults...)
 		}
 	}
 
-	total200 := stats.StatusCodes[200]
-	if total200 > 0 {
-		stats.Average200Time = server200TimeSum / time.Duration(total200)
+	if config.Links.CheckFragments {
+		stats.BrokenFragments = findBrokenFragments(results)
 	}
 
-	if stats.Total == 0 {
-		err = errors.New("No URL crawled")
-	} else if stats.Total != stats.StatusCodes[200] {
-		err = errors.New("Some URLs had a different status code than 200")
+	stats.WallClock = time.Since(start)
+
+	select {
+	case <-quit:
+		stats.Stopped = true
+	default:
 	}
 
+	if stats.Successes > 0 {
+		stats.Average200Time = server200TimeSum / time.Duration(stats.Successes)
+	}
+
+	sortResults(stats.Non200Urls)
+	sortResults(stats.Redirects)
+	sortResults(stats.EmptyBodyUrls)
+	sortParseErrors(stats.ParseErrors)
+
+	if config.Formatter != nil {
+		writeFormatted(config.formatterOutput(), config.Formatter.Summary(stats))
+	}
+
+	if crawlCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("Crawl stopped after %v, results are partial", config.MaxCrawlDuration)
+		return
+	}
+
+	if config.failureLimit.reached() {
+		err = fmt.Errorf("Crawl stopped after %d failures, results are partial", config.MaxFailures)
+		return
+	}
+
+	err = checkStats(stats, config)
 	return
 }
 
+// checkStats returns the error of a crawl whose statistics fail the checks
+// of the configuration, or nil if it passed
+func checkStats(stats CrawlStats, config CrawlConfig) error {
+	if stats.Total == 0 {
+		return errors.New("No URL crawled")
+	} else if stats.Failures(config.FailurePolicy()) > 0 {
+		return errors.New("Some URLs had a status code considered a failure")
+	} else if len(stats.ContentTypeMismatches) > 0 {
+		return errors.New("Some URLs had an unexpected content type")
+	} else if len(stats.Soft404Urls) > 0 {
+		return errors.New("Some URLs look like soft 404 pages")
+	} else if len(stats.EmptyBodyUrls) > 0 {
+		return errors.New("Some URLs answered 200 with an empty body")
+	} else if len(stats.MixedContent) > 0 {
+		return errors.New("Some https pages referenced http resources")
+	} else if len(stats.BrokenFragments) > 0 {
+		return errors.New("Some links pointed to missing fragments")
+	} else if config.FailOnRedirects && len(stats.RedirectedUrls) > 0 {
+		return errors.New("Some sitemap URLs redirected")
+	} else if config.FailOnSlowUrls > 0 && len(stats.SlowUrls) >= config.FailOnSlowUrls {
+		return fmt.Errorf("%d URL(s) were slower than %v", len(stats.SlowUrls), config.SlowThreshold)
+	}
+
+	return nil
+}
+
+// AsyncCrawlContext crawls URLs like AsyncCrawl, stopping and cancelling
+// in-flight requests when ctx is done. The package never installs signal
+// handlers, applications embedding it cancelling ctx on their own signals.
+func AsyncCrawlContext(ctx context.Context, urls []string, config CrawlConfig) (stats CrawlStats, err error) {
+	return AsyncCrawl(urls, config, ctx.Done())
+}
+
+// withMaxDuration returns a context done when quit is closed, or once the
+// duration elapsed unless 0
+func withMaxDuration(quit <-chan struct{}, duration time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if duration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), duration)
+	}
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// withGracePeriod returns a context done once the grace period elapsed after
+// quit was closed
+func withGracePeriod(quit <-chan struct{}, grace time.Duration, logger Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-quit:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(grace):
+			logger.Warn("Grace period elapsed, aborting in-flight requests", nil)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// crawlLinks crawls the links found in sourceResults, then the links found in
+// the linked pages, up to Links.MaxLinkDepth hops from the sitemap URLs.
+// Pages reached through external links are not parsed for further links.
 func crawlLinks(sourceResults []HTTPResponse, sourceURLs []string, sourceConfig CrawlConfig, quit <-chan struct{}) ([]HTTPResponse,
 	CrawlStats, time.Duration) {
 
+	maxDepth := sourceConfig.Links.MaxLinkDepth
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+
+	visitedUrls := make(map[string]bool)
+	for _, alreadyCrawledURL := range sourceURLs {
+		visitedUrls[alreadyCrawledURL] = true
+	}
+	externalUrls := make(map[string]bool)
+
+	var linksResults []HTTPResponse
+	var linksStats CrawlStats
+	var linksServer200TimeSum time.Duration
+	for depth := 1; depth <= maxDepth && len(sourceResults) > 0; depth++ {
+		select {
+		case <-quit:
+			return linksResults, linksStats, linksServer200TimeSum
+		default:
+		}
+
+		levelResults, levelStats, levelServer200TimeSum := crawlLinksLevel(sourceResults, depth,
+			depth < maxDepth, visitedUrls, externalUrls, sourceConfig, quit)
+
+		linksResults = append(linksResults, levelResults...)
+		linksStats = MergeCrawlStats(linksStats, levelStats)
+		linksServer200TimeSum += levelServer200TimeSum
+		sourceResults = levelResults
+	}
+
+	return linksResults, linksStats, linksServer200TimeSum
+}
+
+func crawlLinksLevel(sourceResults []HTTPResponse, depth int, parseLinks bool, visitedUrls map[string]bool,
+	externalUrls map[string]bool, sourceConfig CrawlConfig, quit <-chan struct{}) ([]HTTPResponse, CrawlStats, time.Duration) {
+
 	linkedUrlsSet := make(map[string][]string)
+	var linkedUrls []string
+	linkTypes := make(map[string]LinkType)
 	for _, result := range sourceResults {
+		if externalUrls[result.URL] {
+			continue
+		}
+
 		for _, link := range result.Links {
 			if link.IsExternal && !sourceConfig.Links.CrawlExternalLinks {
 				continue
@@ -170,42 +911,117 @@ func crawlLinks(sourceResults []HTTPResponse, sourceURLs []string, sourceConfig
 				continue
 			}
 
-			linkedUrlsSet[link.TargetURL.String()] = append(linkedUrlsSet[link.TargetURL.String()], result.URL)
+			if link.Type == Alternate && !sourceConfig.Links.CrawlAlternateLinks {
+				continue
+			}
+
+			if link.Type == Stylesheet && !sourceConfig.Links.CrawlStylesheets {
+				continue
+			}
+
+			if link.Type == Script && !sourceConfig.Links.CrawlScripts {
+				continue
+			}
+
+			if sourceConfig.Normalize != nil {
+				link.TargetURL = *sourceConfig.Normalize(&link.TargetURL)
+			}
+
+			if !allowedLinkPrefix(link.TargetURL, sourceConfig.Links.LinkPrefixAllow) {
+				continue
+			}
+
+			targetURL := link.TargetURL.String()
+			if sourceConfig.Links.NormalizeURLs {
+				targetURL = normalizeURL(link.TargetURL)
+			}
+			if visitedUrls[targetURL] {
+				continue
+			}
+
+			if link.IsExternal {
+				externalUrls[targetURL] = true
+			}
+			if _, found := linkedUrlsSet[targetURL]; !found {
+				linkTypes[targetURL] = link.Type
+				linkedUrls = append(linkedUrls, targetURL)
+			}
+			linkingURLs := linkedUrlsSet[targetURL]
+			if len(linkingURLs) == 0 || linkingURLs[len(linkingURLs)-1] != result.URL {
+				linkedUrlsSet[targetURL] = append(linkingURLs, result.URL)
+			}
 		}
 	}
 
-	for _, alreadyCrawledURL := range sourceURLs {
-		delete(linkedUrlsSet, alreadyCrawledURL)
+	for _, url := range linkedUrls {
+		visitedUrls[url] = true
 	}
+	linkedUrls = filterRobotsTxtDisallowed(linkedUrls, sourceConfig)
+
+	linksConfig := sourceConfig
+	linksConfig.HTTP.ParseLinks = parseLinks || sourceConfig.DetectMixedContent || sourceConfig.Links.CheckFragments
 
-	linkedUrls := make([]string, 0, len(linkedUrlsSet))
-	for url := range linkedUrlsSet {
-		linkedUrls = append(linkedUrls, url)
+	sourceConfig.logger().Info(fmt.Sprintf("Found %d relevant linked URL(s) at depth %d", len(linkedUrls), depth),
+		Fields{"count": len(linkedUrls), "depth": depth})
+	sources := urlSources{
+		depth:       depth,
+		linkingURLs: linkedUrlsSet,
+		linkTypes:   linkTypes,
+		external:    externalUrls,
 	}
+	return crawlUrls(linkedUrls, sources, linksConfig, quit)
+}
 
-	linksConfig := sourceConfig
-	linksConfig.HTTP.ParseLinks = false
-	linksConfig.Links = CrawlLinksConfig{
-		CrawlExternalLinks: false,
-		CrawlImages:        false,
-		CrawlHyperlinks:    false}
+func filterRobotsTxtDisallowed(urls []string, config CrawlConfig) []string {
+	if config.HTTP.robots == nil {
+		return urls
+	}
 
-	log.Info("Found ", len(linkedUrls), " relevant linked URL(s)")
-	linksResults, linksStats, linksServer200TimeSum := crawlUrls(linkedUrls, linksConfig, quit)
+	allowedUrls := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if config.HTTP.robots.allowed(url) {
+			allowedUrls = append(allowedUrls, url)
+		}
+	}
 
-	for i, linkResult := range linksStats.Non200Urls {
-		linkResult.LinkingURLs = linkedUrlsSet[linkResult.URL]
-		linksStats.Non200Urls[i] = linkResult
+	if skipped := len(urls) - len(allowedUrls); skipped > 0 {
+		config.logger().Info(fmt.Sprintf("Skipping %d URL(s) disallowed by robots.txt", skipped), Fields{"count": skipped})
 	}
 
-	return linksResults, linksStats, linksServer200TimeSum
+	return allowedUrls
 }
 
-func crawlUrls(urls []string, config CrawlConfig, quit <-chan struct{}) (results []HTTPResponse,
-	stats CrawlStats, server200TimeSum time.Duration) {
+// urlSources describes where crawled URLs were found. Sitemap URLs have no
+// linking URLs, and are considered hyperlinks.
+type urlSources struct {
+	// depth is the number of link hops from the sitemap URLs
+	depth       int
+	linkingURLs map[string][]string
+	linkTypes   map[string]LinkType
+	external    map[string]bool
+}
+
+func crawlUrls(urls []string, sources urlSources, config CrawlConfig,
+	quit <-chan struct{}) (results []HTTPResponse, stats CrawlStats, server200TimeSum time.Duration) {
 
 	stats.StatusCodes = make(map[int]int)
+	hosts := newHostStats()
+	defer func() {
+		stats.ByHost = hosts.byHost()
+	}()
+
+	urls, recorded := config.checkpoint.pending(urls)
+	for _, crawlResult := range recorded {
+		updateCrawlStats(crawlResult, config, &stats, &server200TimeSum)
+		stats.Results = append(stats.Results, crawlResult)
+		hosts.update(crawlResult, config)
+	}
+
+	config.progress.add(len(urls))
 	resultsChan := config.HTTPGetter.ConcurrentHTTPGet(urls, config.HTTP, config.Throttle, quit)
+	if config.PreserveOrder {
+		resultsChan = inURLOrder(urls, resultsChan)
+	}
 	for {
 		select {
 		case result, channelOpen := <-resultsChan:
@@ -213,34 +1029,198 @@ func crawlUrls(urls []string, config CrawlConfig, quit <-chan struct{}) (results
 				return
 			}
 
-			updateCrawlStats(result, &stats, &server200TimeSum)
+			if config.PreserveOrder && config.Formatter == nil {
+				printResult(result, config.HTTP.logger())
+			}
+
+			crawlResult := newCrawlResult(result, sources.depth, sources.linkingURLs[result.URL])
+			crawlResult.ExpectedContentType = config.Links.ExpectedContentTypes[sources.linkTypes[result.URL]]
+			updateCrawlStats(crawlResult, config, &stats, &server200TimeSum)
+			stats.Results = append(stats.Results, crawlResult)
+			hosts.update(crawlResult, config)
+			config.failureLimit.record(crawlResult, config.logger())
+			config.checkpoint.record(crawlResult)
+			config.deadLetters.record(crawlResult)
+			if result.anchors != nil && sources.external[result.URL] {
+				result.anchors.external = true
+			}
+			for _, parseErr := range result.ParseErrors {
+				stats.ParseErrors = append(stats.ParseErrors, ParseError{URL: result.URL, Error: parseErr.Error()})
+			}
+			if config.DetectMixedContent && !sources.external[result.URL] {
+				stats.MixedContent = append(stats.MixedContent, findMixedContent(result)...)
+			}
+			config.Metrics.observe(result.StatusCode, crawlResult.Time)
+			if config.Formatter != nil {
+				writeFormatted(config.formatterOutput(), config.Formatter.Format(crawlResult))
+			}
+			if config.OnResult != nil {
+				config.OnResult(crawlResult, config.progress.complete())
+			}
 			results = append(results, *result)
 		}
 	}
 }
 
-func updateCrawlStats(result *HTTPResponse, stats *CrawlStats, total200Time *time.Duration) {
-	stats.Total++
+// inURLOrder returns a channel receiving the results in the order of their
+// URLs, buffering the results completed early. Once results is closed, the
+// results received are flushed in order, skipping the URLs never completed.
+func inURLOrder(urls []string, results <-chan *HTTPResponse) <-chan *HTTPResponse {
+	ordered := make(chan *HTTPResponse, len(urls))
 
-	statusCode := result.StatusCode
-	serverTime := time.Duration(0)
+	positions := make(map[string][]int)
+	for index, url := range urls {
+		positions[url] = append(positions[url], index)
+	}
+
+	go func() {
+		defer close(ordered)
+
+		completed := make([]*HTTPResponse, len(urls))
+		next := 0
+		for result := range results {
+			indexes := positions[result.URL]
+			if len(indexes) == 0 {
+				// Not one of the URLs, like a result of a custom getter
+				ordered <- result
+				continue
+			}
+			completed[indexes[0]] = result
+			positions[result.URL] = indexes[1:]
+
+			for next < len(urls) && completed[next] != nil {
+				ordered <- completed[next]
+				completed[next] = nil
+				next++
+			}
+		}
+
+		for ; next < len(urls); next++ {
+			if completed[next] != nil {
+				ordered <- completed[next]
+			}
+		}
+	}()
+
+	return ordered
+}
+
+func newCrawlResult(result *HTTPResponse, depth int, linkingURLs []string) CrawlResult {
+	crawlResult := CrawlResult{
+		URL:           result.URL,
+		Time:          result.serverTime(),
+		StatusCode:    result.StatusCode,
+		LinkingURLs:   linkingURLs,
+		Attempts:      result.Attempts,
+		RetryAfter:    result.RetryAfterWaited,
+		Error:         errorString(result.Err),
+		ErrorKind:     result.ErrorKind,
+		Depth:         depth,
+		ContentType:   result.ContentType,
+		BodySize:      result.BodySize,
+		Headers:       result.Headers,
+		Soft404Match:  result.Soft404Match,
+		CacheStatus:   result.CacheStatus,
+		AddressFamily: result.AddressFamily,
+	}
 	if result.Result != nil {
-		serverTime = result.Result.Total(result.EndTime)
+		crawlResult.Phases = PhaseTimes{
+			DNS:     result.Result.DNSLookup,
+			Connect: result.Result.TCPConnection,
+			TLS:     result.Result.TLSHandshake,
+			TTFB:    result.Result.StartTransfer,
+		}
+	}
+	if len(result.RedirectChain) > 0 {
+		crawlResult.FinalURL = result.FinalURL
+		crawlResult.RedirectChain = result.RedirectChain
 	}
 
+	return crawlResult
+}
+
+// updateCrawlStats adds the result to the statistics, the time statistics
+// covering the successful responses. The result is not added to Results, only
+// the statistics of the crawl holding every result, rather than the per host
+// and live statistics.
+func updateCrawlStats(result CrawlResult, config CrawlConfig, stats *CrawlStats,
+	total200Time *time.Duration) {
+	stats.Total++
+	stats.TotalBytes += result.BodySize
+	stats.successCodes = config.SuccessCodes
+
+	statusCode := result.StatusCode
+	serverTime := result.Time
+
 	stats.StatusCodes[statusCode]++
+	stats.Cache.record(result.CacheStatus)
+	if result.ErrorKind != ErrorKindNone {
+		if stats.ErrorKinds == nil {
+			stats.ErrorKinds = make(map[ErrorKind]int)
+		}
+		stats.ErrorKinds[result.ErrorKind]++
+	}
 
-	if statusCode == 200 {
+	if config.isSuccess(statusCode) {
+		stats.Successes++
 		*total200Time += serverTime
+		stats.Times200 = append(stats.Times200, serverTime)
 
 		if serverTime > stats.Max200Time {
 			stats.Max200Time = serverTime
 		}
+
+		// Only 200 responses are expected to have a content
+		if statusCode == 200 && !result.hasExpectedContentType() {
+			stats.ContentTypeMismatches = append(stats.ContentTypeMismatches, result)
+		}
+
+		if statusCode == 200 && result.Soft404Match != "" {
+			stats.Soft404Urls = append(stats.Soft404Urls, result)
+		}
+
+		// Bodies are not read by HEAD requests, nor discarded
+		if config.FailOnEmptyBody && statusCode == 200 && result.BodySize == 0 && config.HTTP.readsBody() {
+			stats.EmptyBodyUrls = append(stats.EmptyBodyUrls, result)
+		}
+
+		if result.Depth == 0 && len(result.RedirectChain) > 0 {
+			stats.RedirectedUrls = append(stats.RedirectedUrls, result)
+		}
+
+		if config.SlowThreshold > 0 && serverTime > config.SlowThreshold {
+			stats.SlowUrls = append(stats.SlowUrls, result)
+		}
+	} else if statusCode == 304 {
+		stats.NotModified++
+	} else if isRedirectStatus(statusCode) {
+		stats.Redirects = append(stats.Redirects, result)
 	} else {
-		stats.Non200Urls = append(stats.Non200Urls, CrawlResult{
-			URL:        result.URL,
-			Time:       serverTime,
-			StatusCode: statusCode,
-		})
+		stats.Non200Urls = append(stats.Non200Urls, result)
 	}
 }
+
+// sortResults sorts the results by URL, then by status code, so that reports
+// do not depend on the completion order
+func sortResults(results []CrawlResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].URL != results[j].URL {
+			return results[i].URL < results[j].URL
+		}
+		return results[i].StatusCode < results[j].StatusCode
+	})
+}
+
+// sortParseErrors sorts the errors by page URL, keeping the order of the
+// errors of a page
+func sortParseErrors(parseErrors []ParseError) {
+	sort.SliceStable(parseErrors, func(i, j int) bool { return parseErrors[i].URL < parseErrors[j].URL })
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+)
+
+// Crawler runs a crawl like AsyncCrawl in the background, streaming its
+// results and exposing its statistics while it runs
+type Crawler struct {
+	urls    []string
+	config  CrawlConfig
+	results chan CrawlResult
+	done    chan struct{}
+
+	mutex            sync.Mutex
+	stats            CrawlStats
+	server200TimeSum time.Duration
+	finished         bool
+	err              error
+}
+
+// NewCrawler returns a Crawler of the URLs, started with Start
+func NewCrawler(urls []string, config CrawlConfig) *Crawler {
+	return &Crawler{
+		urls:   urls,
+		config: config,
+		done:   make(chan struct{}),
+		stats:  CrawlStats{StatusCodes: make(map[int]int)},
+	}
+}
+
+// AsyncCrawlStream crawls URLs like AsyncCrawl in the background, returning
+// a channel receiving each result as it arrives, closed once the crawl is
+// finished or stopped. The channel must be consumed until closed, and the
+// statistics and error of the crawl are then returned by the Wait method of
+// the returned Crawler.
+func AsyncCrawlStream(urls []string, config CrawlConfig, quit <-chan struct{}) (<-chan CrawlResult, *Crawler) {
+	crawler := NewCrawler(urls, config)
+	results := crawler.Results()
+	crawler.Start(quit)
+
+	return results, crawler
+}
+
+// Results returns a channel receiving the result of each crawled URL, closed
+// once the crawl is done. It must be called before Start, and the channel
+// consumed until closed, unless the crawl is stopped.
+func (crawler *Crawler) Results() <-chan CrawlResult {
+	if crawler.results == nil {
+		crawler.results = make(chan CrawlResult)
+	}
+
+	return crawler.results
+}
+
+// Start starts crawling in the background, until done or quit is closed. It
+// must only be called once.
+func (crawler *Crawler) Start(quit <-chan struct{}) {
+	config := crawler.config
+	onResult := config.OnResult
+	config.OnResult = func(result CrawlResult, progress Progress) {
+		crawler.record(result)
+		if crawler.results != nil {
+			select {
+			case crawler.results <- result:
+			case <-quit:
+			}
+		}
+		if onResult != nil {
+			onResult(result, progress)
+		}
+	}
+
+	go func() {
+		stats, err := crawl(crawler.urls, config, quit)
+
+		crawler.mutex.Lock()
+		crawler.stats = stats
+		crawler.err = err
+		crawler.finished = true
+		crawler.mutex.Unlock()
+
+		if crawler.results != nil {
+			close(crawler.results)
+		}
+		close(crawler.done)
+	}()
+}
+
+// Wait waits for the crawl to be done, and returns its statistics and error
+// like AsyncCrawl
+func (crawler *Crawler) Wait() (CrawlStats, error) {
+	<-crawler.done
+	return crawler.stats, crawler.err
+}
+
+// Snapshot returns a consistent copy of the statistics of the URLs crawled
+// so far, or of the final statistics once the crawl is done, which can be
+// polled while the crawl runs. Results resumed from a checkpoint are only
+// included in the final statistics, like the Results field, the results
+// being streamed by Results meanwhile.
+func (crawler *Crawler) Snapshot() CrawlStats {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	stats := crawler.stats.clone()
+	if !crawler.finished && stats.Successes > 0 {
+		stats.Average200Time = crawler.server200TimeSum / time.Duration(stats.Successes)
+	}
+
+	return stats
+}
+
+// record adds a result to the statistics of the running crawl
+func (crawler *Crawler) record(result CrawlResult) {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	updateCrawlStats(result, crawler.config, &crawler.stats, &crawler.server200TimeSum)
+}
@@ -0,0 +1,38 @@
+package crawler
+
+import "fmt"
+
+// deadLetters writes the failing results of a crawl to DeadLetterWriter as
+// they arrive, so that they can be retried by a later crawl
+type deadLetters struct {
+	writer *NDJSONWriter
+	failed StatusPolicy
+	logger Logger
+}
+
+// newDeadLetters returns the dead letters of the crawl, or nil if
+// DeadLetterWriter is not set
+func newDeadLetters(config CrawlConfig) *deadLetters {
+	if config.DeadLetterWriter == nil {
+		return nil
+	}
+
+	return &deadLetters{
+		writer: NewNDJSONWriter(config.DeadLetterWriter),
+		failed: config.FailurePolicy(),
+		logger: config.logger(),
+	}
+}
+
+// record writes the result if it is a failure. Nothing is written once an
+// error was encountered, the error being logged once.
+func (letters *deadLetters) record(result CrawlResult) {
+	if letters == nil || letters.writer.Error() != nil || !letters.failed(result.StatusCode) {
+		return
+	}
+
+	letters.writer.OnResult(result, Progress{})
+	if err := letters.writer.Error(); err != nil {
+		letters.logger.Error(fmt.Sprintf("Failed to write dead letter: %v", err), nil)
+	}
+}
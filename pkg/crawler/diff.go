@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"sort"
+	"time"
+)
+
+// StatsDiff holds the changes between the results of two crawls, like before
+// and after a deployment. URLs crawled by both are compared following
+// DefaultFailOn: NewlyFailing and NewlyPassing hold the URLs which started or
+// stopped failing, and StatusChanged the others whose status code changed,
+// like a 404 becoming a 410. Added and Removed hold the URLs crawled by a
+// single of the crawls. Each list is sorted by URL.
+type StatsDiff struct {
+	NewlyFailing  []URLChange `json:"newly-failing"`
+	NewlyPassing  []URLChange `json:"newly-passing"`
+	StatusChanged []URLChange `json:"status-changed"`
+	Added         []string    `json:"added"`
+	Removed       []string    `json:"removed"`
+}
+
+// URLChange holds the status code and server time of a URL in both crawls
+type URLChange struct {
+	URL              string        `json:"url"`
+	StatusCodeBefore int           `json:"status-code-before"`
+	StatusCodeAfter  int           `json:"status-code-after"`
+	TimeBefore       time.Duration `json:"server-time-before"`
+	TimeAfter        time.Duration `json:"server-time-after"`
+}
+
+// HasChanges returns whether any URL changed between the crawls
+func (diff StatsDiff) HasChanges() bool {
+	return len(diff.NewlyFailing) > 0 || len(diff.NewlyPassing) > 0 || len(diff.StatusChanged) > 0 ||
+		len(diff.Added) > 0 || len(diff.Removed) > 0
+}
+
+// DiffCrawlStats compares the results of two crawls. The last result of a
+// URL crawled several times is the one compared.
+func DiffCrawlStats(before, after CrawlStats) (diff StatsDiff) {
+	beforeResults := latestResults(before.Results)
+	afterResults := latestResults(after.Results)
+
+	for url, afterResult := range afterResults {
+		beforeResult, found := beforeResults[url]
+		if !found {
+			diff.Added = append(diff.Added, url)
+			continue
+		}
+
+		if beforeResult.StatusCode == afterResult.StatusCode {
+			continue
+		}
+
+		change := URLChange{
+			URL:              url,
+			StatusCodeBefore: beforeResult.StatusCode,
+			StatusCodeAfter:  afterResult.StatusCode,
+			TimeBefore:       beforeResult.Time,
+			TimeAfter:        afterResult.Time,
+		}
+
+		failedBefore := DefaultFailOn(beforeResult.StatusCode)
+		failsAfter := DefaultFailOn(afterResult.StatusCode)
+		switch {
+		case failsAfter && !failedBefore:
+			diff.NewlyFailing = append(diff.NewlyFailing, change)
+		case failedBefore && !failsAfter:
+			diff.NewlyPassing = append(diff.NewlyPassing, change)
+		default:
+			diff.StatusChanged = append(diff.StatusChanged, change)
+		}
+	}
+
+	for url := range beforeResults {
+		if _, found := afterResults[url]; !found {
+			diff.Removed = append(diff.Removed, url)
+		}
+	}
+
+	sortURLChanges(diff.NewlyFailing)
+	sortURLChanges(diff.NewlyPassing)
+	sortURLChanges(diff.StatusChanged)
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+
+	return
+}
+
+// latestResults returns the last result of each URL
+func latestResults(results []CrawlResult) map[string]CrawlResult {
+	latest := make(map[string]CrawlResult, len(results))
+	for _, result := range results {
+		latest[result.URL] = result
+	}
+
+	return latest
+}
+
+func sortURLChanges(changes []URLChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].URL < changes[j].URL
+	})
+}
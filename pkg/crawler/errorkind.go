@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrorKind classifies why a request failed, so that failures can be grouped
+// and routed, DNS problems and application errors usually going to different
+// teams
+type ErrorKind string
+
+const (
+	// ErrorKindNone is the kind of successful requests
+	ErrorKindNone ErrorKind = ""
+	// ErrorKindDNS is the kind of requests whose host could not be resolved
+	ErrorKindDNS ErrorKind = "dns"
+	// ErrorKindConnect is the kind of requests whose connection could not be
+	// established, like refused connections
+	ErrorKindConnect ErrorKind = "connect"
+	// ErrorKindTLS is the kind of requests whose TLS handshake failed, like
+	// invalid certificates
+	ErrorKindTLS ErrorKind = "tls"
+	// ErrorKindTimeout is the kind of requests which timed out
+	ErrorKindTimeout ErrorKind = "timeout"
+	// ErrorKindHTTPStatus is the kind of responses with a 4xx or 5xx status
+	ErrorKindHTTPStatus ErrorKind = "http-status"
+	// ErrorKindRead is the kind of responses whose body could not be read
+	ErrorKindRead ErrorKind = "read"
+	// ErrorKindOther is the kind of the other errors, like aborted requests
+	// or HTTP/2 not being negotiated
+	ErrorKindOther ErrorKind = "other"
+)
+
+// mergeErrorKinds returns the sum of the counts of each error kind, or nil if
+// there are none
+func mergeErrorKinds(kindsA, kindsB map[ErrorKind]int) map[ErrorKind]int {
+	if len(kindsA) == 0 && len(kindsB) == 0 {
+		return nil
+	}
+
+	kinds := make(map[ErrorKind]int)
+	for kind, count := range kindsA {
+		kinds[kind] += count
+	}
+	for kind, count := range kindsB {
+		kinds[kind] += count
+	}
+
+	return kinds
+}
+
+// sortedErrorKinds returns the error kinds of the map in alphabetical order
+func sortedErrorKinds(kinds map[ErrorKind]int) []ErrorKind {
+	sorted := make([]ErrorKind, 0, len(kinds))
+	for kind := range kinds {
+		sorted = append(sorted, kind)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return sorted
+}
+
+// classifyError returns the kind of error of a response. A DNS lookup timing
+// out is a DNS error rather than a timeout.
+func classifyError(response *HTTPResponse) ErrorKind {
+	err := response.Err
+	if err == nil {
+		if response.StatusCode >= 400 {
+			return ErrorKindHTTPStatus
+		}
+		return ErrorKindNone
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorKindDNS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorKindTimeout
+	}
+
+	if isTLSError(err) {
+		return ErrorKindTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorKindConnect
+	}
+
+	return ErrorKindOther
+}
+
+// isTLSError returns whether the error is a certificate verification or TLS
+// handshake error. TLS alerts are not exported by crypto/tls, and are matched
+// by their message.
+func isTLSError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+
+	return errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &recordHeader) ||
+		strings.Contains(err.Error(), "tls: ")
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// LinkExtractor returns the links found in a response body of currentURL,
+// set in HTTPConfig.LinkExtractors for the media types it parses. Relative
+// links are resolved against currentURL, and links to other hosts flagged as
+// external, once returned. Links are hyperlinks unless their Type is set.
+type LinkExtractor func(body io.Reader, currentURL url.URL) ([]Link, error)
+
+// linkExtractor returns the extractor registered for the media type of the
+// content type, or nil if there is none
+func (config HTTPConfig) linkExtractor(contentType string) LinkExtractor {
+	if len(config.LinkExtractors) == 0 || contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	return config.LinkExtractors[mediaType]
+}
+
+// ExtractJSONLinks is a LinkExtractor returning the string values of a JSON
+// document that are absolute http or https URLs, or absolute paths, as
+// hyperlinks in document order. Object keys are ignored.
+func ExtractJSONLinks(body io.Reader, currentURL url.URL) ([]Link, error) {
+	const (
+		objectKey = iota
+		objectValue
+		array
+	)
+
+	// containers holds the state of the objects and arrays being decoded
+	var containers []int
+	valueDone := func() {
+		if top := len(containers) - 1; top >= 0 && containers[top] == objectValue {
+			containers[top] = objectKey
+		}
+	}
+
+	var links []Link
+	decoder := json.NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF && len(containers) > 0 {
+			return nil, fmt.Errorf("invalid JSON: %w", io.ErrUnexpectedEOF)
+		} else if err == io.EOF {
+			return links, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		top := len(containers) - 1
+		if top >= 0 && containers[top] == objectKey {
+			if _, isKey := token.(string); isKey {
+				containers[top] = objectValue
+				continue
+			}
+		}
+
+		switch token := token.(type) {
+		case json.Delim:
+			switch token {
+			case '{':
+				containers = append(containers, objectKey)
+			case '[':
+				containers = append(containers, array)
+			default:
+				containers = containers[:top]
+				valueDone()
+			}
+		case string:
+			if link := jsonLink(token); link != nil {
+				links = append(links, *link)
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
+
+// jsonLink returns the hyperlink to a JSON string value, or nil if it is not
+// an absolute URL or path
+func jsonLink(value string) *Link {
+	isPath := strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//")
+	if !isPath && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return nil
+	}
+
+	targetURL, err := url.Parse(value)
+	if err != nil || strings.ContainsAny(value, " \t\n") {
+		return nil
+	}
+
+	return &Link{Type: Hyperlink, Name: "JSON link", TargetURL: *targetURL}
+}
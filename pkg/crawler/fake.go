@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeResponse is the scripted response of a FakeHTTPGetter to a URL. Delay
+// is both waited before responding and reported as the time of the request.
+type FakeResponse struct {
+	StatusCode  int
+	Delay       time.Duration
+	Err         error
+	ContentType string
+	BodySize    int64
+	Headers     map[string]string
+}
+
+// FakeHTTPGetter is a ConcurrentHTTPGetter answering scripted responses
+// without any network access, so that crawls can be tested
+// deterministically. URLs without a response are answered with Default,
+// a 404 if not set. Requests go through RunConcurrentGet, honoring the
+// throttling, retries and quit channel of the crawl.
+type FakeHTTPGetter struct {
+	Default   *FakeResponse
+	mutex     sync.Mutex
+	responses map[string]FakeResponse
+	requested []string
+}
+
+// NewFakeHTTPGetter returns a FakeHTTPGetter without scripted responses
+func NewFakeHTTPGetter() *FakeHTTPGetter {
+	return &FakeHTTPGetter{responses: make(map[string]FakeResponse)}
+}
+
+// Respond scripts the response to url, replacing any previous one
+func (getter *FakeHTTPGetter) Respond(url string, response FakeResponse) *FakeHTTPGetter {
+	getter.mutex.Lock()
+	defer getter.mutex.Unlock()
+
+	getter.responses[url] = response
+	return getter
+}
+
+// RespondStatus scripts url to be answered with the status code
+func (getter *FakeHTTPGetter) RespondStatus(url string, statusCode int) *FakeHTTPGetter {
+	return getter.Respond(url, FakeResponse{StatusCode: statusCode})
+}
+
+// RespondAfter scripts url to be answered with the status code after delay
+func (getter *FakeHTTPGetter) RespondAfter(url string, statusCode int, delay time.Duration) *FakeHTTPGetter {
+	return getter.Respond(url, FakeResponse{StatusCode: statusCode, Delay: delay})
+}
+
+// RespondError scripts url to fail with err, without status code
+func (getter *FakeHTTPGetter) RespondError(url string, err error) *FakeHTTPGetter {
+	return getter.Respond(url, FakeResponse{Err: err})
+}
+
+// Requested returns the URLs requested so far, in the order of the requests
+func (getter *FakeHTTPGetter) Requested() []string {
+	getter.mutex.Lock()
+	defer getter.mutex.Unlock()
+
+	return append([]string(nil), getter.requested...)
+}
+
+// ConcurrentHTTPGet answers the urls with their scripted responses
+func (getter *FakeHTTPGetter) ConcurrentHTTPGet(urls []string, config HTTPConfig,
+	maxConcurrent int, quit <-chan struct{}) <-chan *HTTPResponse {
+
+	resultChan := make(chan *HTTPResponse, len(urls))
+
+	go RunConcurrentGet(getter.Get, urls, config, maxConcurrent, resultChan, quit)
+
+	return resultChan
+}
+
+// Get is the HTTPGetter answering url with its scripted response, after its
+// delay. Like HTTPGet, it fails once the request timeout is reached or the
+// crawl is aborted.
+func (getter *FakeHTTPGetter) Get(url string, config HTTPConfig) *HTTPResponse {
+	getter.mutex.Lock()
+	getter.requested = append(getter.requested, url)
+	scripted, found := getter.responses[url]
+	if !found {
+		scripted = FakeResponse{StatusCode: 404}
+		if getter.Default != nil {
+			scripted = *getter.Default
+		}
+	}
+	getter.mutex.Unlock()
+
+	ctx, cancel := requestContext(config)
+	defer cancel()
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return &HTTPResponse{URL: url, EndTime: time.Now(), Err: ctx.Err(), elapsed: time.Since(start)}
+	case <-time.After(scripted.Delay):
+	}
+
+	return &HTTPResponse{
+		URL:         url,
+		StatusCode:  scripted.StatusCode,
+		EndTime:     time.Now(),
+		Err:         scripted.Err,
+		ContentType: scripted.ContentType,
+		BodySize:    scripted.BodySize,
+		Headers:     scripted.Headers,
+		elapsed:     scripted.Delay,
+	}
+}
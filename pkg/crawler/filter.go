@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlFilter selects the URLs to crawl from regular expressions. A URL is
+// crawled if it matches any include pattern, or if there are none, and
+// matches no exclude pattern.
+type urlFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func newURLFilter(includePatterns, excludePatterns []string) (filter urlFilter, err error) {
+	filter.include, err = compilePatterns(includePatterns)
+	if err != nil {
+		return
+	}
+
+	filter.exclude, err = compilePatterns(excludePatterns)
+	return
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regexp, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexps = append(regexps, regexp)
+	}
+
+	return regexps, nil
+}
+
+func (filter urlFilter) match(url string) bool {
+	for _, exclude := range filter.exclude {
+		if exclude.MatchString(url) {
+			return false
+		}
+	}
+
+	if len(filter.include) == 0 {
+		return true
+	}
+
+	for _, include := range filter.include {
+		if include.MatchString(url) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter urlFilter) apply(urls []string) []string {
+	if len(filter.include) == 0 && len(filter.exclude) == 0 {
+		return urls
+	}
+
+	filtered := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if filter.match(url) {
+			filtered = append(filtered, url)
+		}
+	}
+
+	return filtered
+}
+
+// FilterUrls returns the URLs matching the include and exclude patterns of
+// the configuration, as crawled by AsyncCrawl
+func FilterUrls(urls []string, config CrawlConfig) ([]string, error) {
+	filter, err := newURLFilter(config.IncludePatterns, config.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.apply(urls), nil
+}
+
+// allowedLinkPrefix returns whether the path of a linked URL starts with one
+// of the allowed prefixes, any path being allowed if there are none
+func allowedLinkPrefix(target url.URL, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	path := target.Path
+	if path == "" {
+		path = "/"
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
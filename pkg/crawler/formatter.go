@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ResultFormatter formats the result of each crawled URL as it arrives, and
+// the statistics of the crawl once done. Empty strings are not written.
+type ResultFormatter interface {
+	Format(result CrawlResult) string
+	Summary(stats CrawlStats) string
+}
+
+// formatterOutput returns the writer formatted results are written to,
+// stdout if not configured
+func (config CrawlConfig) formatterOutput() io.Writer {
+	if config.FormatterOutput == nil {
+		return os.Stdout
+	}
+
+	return config.FormatterOutput
+}
+
+// writeFormatted writes the formatted text followed by a new line, unless
+// empty
+func writeFormatted(w io.Writer, text string) {
+	if text == "" {
+		return
+	}
+
+	fmt.Fprintln(w, text)
+}
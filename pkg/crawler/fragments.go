@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// BrokenFragment is a hyperlink to a fragment that is neither an element id
+// nor an anchor name of its target page
+type BrokenFragment struct {
+	SourceURL string `json:"source-url"`
+	TargetURL string `json:"target-url"`
+	Fragment  string `json:"fragment"`
+}
+
+// pageAnchors holds the fragments a page defines, and the fragments of the
+// same page its hyperlinks point to. The links of external pages are not
+// checked.
+type pageAnchors struct {
+	ids      map[string]bool
+	samePage []string
+	external bool
+}
+
+// extractAnchors returns the element ids and anchor names of the page, and
+// the fragments its '#fragment' hyperlinks point to
+func extractAnchors(doc *goquery.Document) *pageAnchors {
+	anchors := &pageAnchors{ids: make(map[string]bool)}
+	doc.Find("[id]").Each(func(i int, s *goquery.Selection) {
+		id, _ := s.Attr("id")
+		anchors.ids[id] = true
+	})
+	doc.Find("a[name]").Each(func(i int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		anchors.ids[name] = true
+	})
+
+	doc.Find("a[href^='#']").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		fragment, err := url.PathUnescape(strings.TrimPrefix(href, "#"))
+		if err == nil {
+			anchors.samePage = append(anchors.samePage, fragment)
+		}
+	})
+
+	return anchors
+}
+
+// has returns whether the fragment can be scrolled to. The empty fragment
+// and "top" always scroll to the top of the page, while text fragments do
+// not refer to elements.
+func (anchors *pageAnchors) has(fragment string) bool {
+	return fragment == "" || strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, ":~:") ||
+		anchors.ids[fragment]
+}
+
+// pageKey returns the URL without its fragment
+func pageKey(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	parsedURL.Fragment = ""
+	return parsedURL.String()
+}
+
+// findBrokenFragments returns the hyperlinks of the internal pages pointing
+// to a fragment missing from their target page. Links to pages not crawled,
+// or crawled without a 200 response, are not checked.
+func findBrokenFragments(results []HTTPResponse) (broken []BrokenFragment) {
+	pages := make(map[string]*pageAnchors)
+	for _, result := range results {
+		if result.anchors == nil || result.StatusCode != 200 {
+			continue
+		}
+
+		pages[pageKey(result.URL)] = result.anchors
+		if result.FinalURL != "" {
+			pages[pageKey(result.FinalURL)] = result.anchors
+		}
+	}
+
+	reported := make(map[BrokenFragment]bool)
+	report := func(fragment BrokenFragment) {
+		if !reported[fragment] {
+			reported[fragment] = true
+			broken = append(broken, fragment)
+		}
+	}
+
+	for _, result := range results {
+		if result.anchors == nil || result.anchors.external || result.StatusCode != 200 {
+			continue
+		}
+
+		for _, fragment := range result.anchors.samePage {
+			if !result.anchors.has(fragment) {
+				report(BrokenFragment{SourceURL: result.URL, TargetURL: pageKey(result.URL), Fragment: fragment})
+			}
+		}
+
+		for _, link := range result.Links {
+			if link.Type != Hyperlink || link.TargetURL.Fragment == "" {
+				continue
+			}
+
+			target, found := pages[pageKey(link.TargetURL.String())]
+			if found && !target.has(link.TargetURL.Fragment) {
+				report(BrokenFragment{
+					SourceURL: result.URL,
+					TargetURL: pageKey(link.TargetURL.String()),
+					Fragment:  link.TargetURL.Fragment,
+				})
+			}
+		}
+	}
+
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].SourceURL != broken[j].SourceURL {
+			return broken[i].SourceURL < broken[j].SourceURL
+		}
+		if broken[i].TargetURL != broken[j].TargetURL {
+			return broken[i].TargetURL < broken[j].TargetURL
+		}
+		return broken[i].Fragment < broken[j].Fragment
+	})
+
+	return broken
+}
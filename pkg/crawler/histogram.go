@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StatusClass holds the number of responses of a status class, like "4xx",
+// and of each of its status codes. Requests failing without response have
+// the "none" class.
+type StatusClass struct {
+	Class       string      `json:"class"`
+	Total       int         `json:"total"`
+	StatusCodes map[int]int `json:"status-codes"`
+}
+
+// StatusHistogram groups the status codes of the statistics by class,
+// sorted by class
+func (stats CrawlStats) StatusHistogram() []StatusClass {
+	classes := make(map[string]*StatusClass)
+	for statusCode, count := range stats.StatusCodes {
+		name := statusClassName(statusCode)
+		class, found := classes[name]
+		if !found {
+			class = &StatusClass{Class: name, StatusCodes: make(map[int]int)}
+			classes[name] = class
+		}
+
+		class.Total += count
+		class.StatusCodes[statusCode] += count
+	}
+
+	histogram := make([]StatusClass, 0, len(classes))
+	for _, class := range classes {
+		histogram = append(histogram, *class)
+	}
+	sort.Slice(histogram, func(i, j int) bool {
+		return histogram[i].Class < histogram[j].Class
+	})
+
+	return histogram
+}
+
+func statusClassName(statusCode int) string {
+	if statusCode < 100 {
+		return "none"
+	}
+
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// StatusHistogramBars returns the status histogram as text bars of at most
+// width characters, one line per class followed by one line per status code
+func (stats CrawlStats) StatusHistogramBars(width int) []string {
+	histogram := stats.StatusHistogram()
+
+	max := 0
+	for _, class := range histogram {
+		if class.Total > max {
+			max = class.Total
+		}
+	}
+
+	bar := func(count int) string {
+		length := 0
+		if max > 0 {
+			length = (count*width + max - 1) / max
+		}
+		return strings.Repeat("#", length)
+	}
+
+	var lines []string
+	for _, class := range histogram {
+		lines = append(lines, fmt.Sprintf("%-4s %s %d", class.Class, bar(class.Total), class.Total))
+
+		statusCodes := make([]int, 0, len(class.StatusCodes))
+		for statusCode := range class.StatusCodes {
+			statusCodes = append(statusCodes, statusCode)
+		}
+		sort.Ints(statusCodes)
+
+		for _, statusCode := range statusCodes {
+			count := class.StatusCodes[statusCode]
+			lines = append(lines, fmt.Sprintf("    %03d  %s %d", statusCode, bar(count), count))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// hostStats buckets the statistics of a crawl by host
+type hostStats struct {
+	stats            map[string]CrawlStats
+	server200TimeSum map[string]time.Duration
+}
+
+func newHostStats() *hostStats {
+	return &hostStats{
+		stats:            make(map[string]CrawlStats),
+		server200TimeSum: make(map[string]time.Duration),
+	}
+}
+
+// update adds the result to the statistics of its host
+func (hosts *hostStats) update(result CrawlResult, config CrawlConfig) {
+	host := resultHost(result.URL)
+	stats, found := hosts.stats[host]
+	if !found {
+		stats.StatusCodes = make(map[int]int)
+	}
+
+	server200TimeSum := hosts.server200TimeSum[host]
+	updateCrawlStats(result, config, &stats, &server200TimeSum)
+	hosts.stats[host] = stats
+	hosts.server200TimeSum[host] = server200TimeSum
+}
+
+// byHost returns the statistics of each host, or nil if no result was added
+func (hosts *hostStats) byHost() map[string]CrawlStats {
+	if len(hosts.stats) == 0 {
+		return nil
+	}
+
+	byHost := make(map[string]CrawlStats, len(hosts.stats))
+	for host, stats := range hosts.stats {
+		if stats.Successes > 0 {
+			stats.Average200Time = hosts.server200TimeSum[host] / time.Duration(stats.Successes)
+		}
+		byHost[host] = stats
+	}
+
+	return byHost
+}
+
+// resultHost returns the host of the URL, including its port, or the URL
+// itself if it cannot be parsed
+func resultHost(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return rawURL
+	}
+
+	return parsedURL.Host
+}
+
+// sortedHosts returns the hosts of the map in alphabetical order
+func sortedHosts(byHost map[string]CrawlStats) []string {
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// mergeHostStats merges the statistics of the hosts of both maps
+func mergeHostStats(byHostA, byHostB map[string]CrawlStats) map[string]CrawlStats {
+	if len(byHostA) == 0 && len(byHostB) == 0 {
+		return nil
+	}
+
+	byHost := make(map[string]CrawlStats, len(byHostA)+len(byHostB))
+	for host, stats := range byHostA {
+		byHost[host] = stats
+	}
+	for host, stats := range byHostB {
+		if existing, found := byHost[host]; found {
+			stats = MergeCrawlStats(existing, stats)
+		}
+		byHost[host] = stats
+	}
+
+	return byHost
+}
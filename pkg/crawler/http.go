@@ -1,14 +1,18 @@
 package crawler
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/tcnksm/go-httpstat"
 )
 
@@ -20,62 +24,370 @@ type HTTPResponse struct {
 	StatusCode int
 	EndTime    time.Time
 	Err        error
-	Links      []Link
+	// AddressFamily is the family of the address the response was received
+	// from, "ipv4" or "ipv6", the proxy's if any
+	AddressFamily string
+	// ErrorKind classifies Err, or an error status code. It is set by
+	// ConcurrentHTTPGet for the responses of any HTTPGetter, and by HTTPGet
+	// when the body could not be read.
+	ErrorKind ErrorKind
+	Links     []Link
+	Attempts  int
+	// FinalURL is the URL reached after following redirects, and
+	// RedirectChain the status codes of the intermediate responses
+	FinalURL      string
+	RedirectChain []int
+	ContentType   string
+	// BodySize is the number of body bytes read, at most MaxBodyBytes
+	BodySize int64
+	// Proto is the protocol of the response, like "HTTP/2.0"
+	Proto string
+	// Headers holds the values of the captured response headers present
+	Headers map[string]string
+	// ParseErrors holds the errors parsing the body and the links of a page
+	// whose links are parsed, unparseable links being skipped
+	ParseErrors []error
+	// Soft404Match is the soft 404 pattern found in a 200 response body
+	Soft404Match string
+	// CacheStatus is the value of the cache status header, if configured
+	CacheStatus string
+	// anchors holds the anchors of the page, if collected
+	anchors *pageAnchors
+	// RetryAfter is the delay requested by the Retry-After header of a 429
+	// or 503 response, and RetryAfterWaited the sum of the Retry-After
+	// delays waited before the attempts of the request
+	RetryAfter       time.Duration
+	RetryAfterWaited time.Duration
+	// elapsed is the time of a request not timed by Result, like those of a
+	// FakeHTTPGetter or failing to dial
+	elapsed time.Duration
+}
+
+// Redirected returns whether the URL redirected, FinalURL being the URL
+// eventually reached
+func (response *HTTPResponse) Redirected() bool {
+	return len(response.RedirectChain) > 0
+}
+
+// serverTime returns the total time of the request, or 0 if it could not be
+// sent
+func (response *HTTPResponse) serverTime() time.Duration {
+	if !response.connected() {
+		return response.elapsed
+	}
+
+	return response.Result.Total(response.EndTime)
+}
+
+// connected returns whether the request was timed by Result. httpstat only
+// starts timing with the connection, so its total is meaningless when the
+// dial failed early.
+func (response *HTTPResponse) connected() bool {
+	return response.Result != nil && response.Result.Total(response.EndTime) <= response.elapsed
+}
+
+// ErrConflictingAuth is returned when both basic auth credentials and a bearer
+// token are configured
+var ErrConflictingAuth = errors.New("basic auth and bearer token are mutually exclusive")
+
+// HostAuth holds the credentials and headers sent to a single host, instead of
+// the global credentials of HTTPConfig. BearerToken and User are mutually
+// exclusive, and Headers are sent in addition to the global headers.
+type HostAuth struct {
+	User        string
+	Pass        string
+	BearerToken string
+	Headers     map[string]string
 }
 
 // HTTPConfig hold settings used to get pages via HTTP/S
 type HTTPConfig struct {
-	User       string
-	Pass       string
-	Timeout    time.Duration
-	ParseLinks bool
+	User string
+	Pass string
+	// BearerToken is sent as an Authorization header with every request, and
+	// cannot be combined with the User and Pass basic auth credentials
+	BearerToken string
+	// HostAuth holds the credentials of hosts requiring their own, keyed by
+	// host with an optional port, hosts not listed using the global
+	// credentials
+	HostAuth map[string]HostAuth
+	Timeout  time.Duration
+	// RequestTimeout bounds a single request, including reading its body for
+	// links
+	RequestTimeout time.Duration
+	UserAgent      string
+	Headers        map[string]string
+	ParseLinks     bool
+	// LinkExtractors extract the links of the responses of their media type,
+	// like "application/json", when links are parsed, other responses being
+	// parsed as HTML
+	LinkExtractors map[string]LinkExtractor
+	Retry          RetryConfig
+	MaxRedirects   int
+	// Method is the HTTP method of requests, GET if empty. Only GET and HEAD
+	// are supported.
+	Method string
+	// MaxBodyBytes stops reading response bodies after that many bytes if
+	// set, and DiscardBody does not read them at all unless links are parsed
+	MaxBodyBytes int64
+	DiscardBody  bool
+	// Cookies are sent with requests through a cookie jar shared by the
+	// requests of a crawl, Jar if set. Login is an optional form submitted
+	// before crawling to add session cookies to the jar.
+	Cookies []*http.Cookie
+	Jar     http.CookieJar
+	Login   *LoginConfig
+	// ForceHTTP2 attempts HTTP/2 on every connection, and fails requests
+	// answered with another protocol, while DisableHTTP2 only uses HTTP/1.1
+	ForceHTTP2   bool
+	DisableHTTP2 bool
+
+	// MaxIdleConns and MaxIdleConnsPerHost override the connection pool sizes
+	// of net/http if set, and DisableKeepAlives opens a new connection for
+	// every request
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	DisableKeepAlives   bool
+	// Proxy is the URL of an http, https or socks5 proxy, the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables being honored if empty
+	Proxy string
+	// CAFile is the path of a PEM bundle of certificate authorities trusted
+	// in addition to the system ones, while InsecureSkipVerify disables
+	// certificate verification
+	CAFile             string
+	InsecureSkipVerify bool
+	// HostResolve pins hosts, as host or host:port, to another ip or ip:port,
+	// like curl's --resolve, the Host header and TLS server name being kept
+	HostResolve map[string]string
+	// DialNetwork is the network connections are dialed with, "tcp4" or
+	// "tcp6" to only use IPv4 or IPv6, or "tcp" if empty to use either
+	DialNetwork string
+	// CaptureHeaders are the names of the response headers to capture, and
+	// CacheStatusHeader the name of a header telling whether the response was
+	// served from cache, like X-Cache
+	CaptureHeaders    []string
+	CacheStatusHeader string
+	// Soft404Patterns are body substrings, matched ignoring case, revealing
+	// error pages served with a 200 status, in which case bodies are always
+	// read
+	Soft404Patterns []string
+	// IfModifiedSince holds the dates sent as If-Modified-Since by URL, like
+	// the sitemap lastmod, URLs answering 304 being healthy. URLs are looked
+	// up by path and query too, for crawls overriding the origin of URLs.
+	IfModifiedSince map[string]time.Time
+	// Logger receives the logs of requests, the default logrus logger if nil,
+	// and is set to the crawl Logger by AsyncCrawl if unset
+	Logger Logger
+
+	// ifModifiedSinceURIs holds IfModifiedSince by path and query
+	ifModifiedSinceURIs map[string]time.Time
+	// quit aborts in-flight requests, set by RunConcurrentGet, unless abort
+	// is set to let them finish within the grace period of the crawl
+	quit  <-chan struct{}
+	abort <-chan struct{}
+	// robots paces requests to honor robots.txt crawl delays
+	robots *robotsTxtCache
+	// hostThrottle limits concurrent requests per host
+	hostThrottle *hostThrottle
+	// rateLimiter limits the number of requests per second
+	rateLimiter *rateLimiter
+	// rampUp staggers the start of the first requests of a crawl
+	rampUp *rampUp
+	// window pauses the requests outside the time window of the crawl
+	window *windowGuard
+	// breaker pauses the requests to failing hosts
+	breaker *circuitBreaker
+	// checkFragments collects the anchors of parsed pages
+	checkFragments bool
+	// workers is the number of URLs handled at once, waiting for their turn
+	// before being requested, the maximum concurrent requests if 0
+	workers int
+	// transport is shared by the requests of a crawl, nil for the default
+	transport *http.Transport
+	// formatted disables the logging of results, formatted or logged in URL
+	// order by the crawl
+	formatted bool
+	// attempt is the number of the request attempt, set by getWithRetry
+	attempt int
+}
+
+// requestMethod returns the method to use for requests. Pages whose links are
+// parsed always use GET, as a HEAD response has no body.
+func (config HTTPConfig) requestMethod() string {
+	if config.ParseLinks || config.Method == "" {
+		return http.MethodGet
+	}
+
+	return config.Method
+}
+
+// readsBody returns whether the bodies of 200 responses are read, and their
+// BodySize known
+func (config HTTPConfig) readsBody() bool {
+	if config.requestMethod() != http.MethodGet {
+		return false
+	}
+
+	return config.ParseLinks || !config.DiscardBody || len(config.Soft404Patterns) > 0
 }
 
 // HTTPGetter performs a single HTTP/S  to the url, and return information
 // related to the result as an HTTPResponse
 type HTTPGetter func(url string, config HTTPConfig) (response *HTTPResponse)
 
-func createRequest(url string) (*http.Request, *httpstat.Result, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func createRequest(ctx context.Context, method string, url string) (*http.Request, *httpstat.Result, error) {
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
-		log.Error(err)
 		return nil, nil, err
 	}
 
 	// create a httpstat powered context
 	result := &httpstat.Result{}
-	ctx := httpstat.WithHTTPStat(req.Context(), result)
+	ctx = httpstat.WithHTTPStat(ctx, result)
 	req = req.WithContext(ctx)
 
 	return req, result, nil
 }
 
+// requestContext returns a context cancelled when the request timeout is
+// reached, or when the crawl is stopped and its grace period elapsed
+func requestContext(config HTTPConfig) (context.Context, context.CancelFunc) {
+	abort := config.quit
+	if config.abort != nil {
+		abort = config.abort
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if config.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), config.RequestTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	go func() {
+		select {
+		case <-abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
 func configureRequest(req *http.Request, config HTTPConfig) {
-	if len(config.User) > 0 {
-		req.SetBasicAuth(config.User, config.Pass)
+	if len(config.UserAgent) > 0 {
+		req.Header.Set("User-Agent", config.UserAgent)
+	}
+
+	if since, found := config.modifiedSince(req.URL.String()); found {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+
+	for name, value := range config.Headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(name, value)
+		}
 	}
+
+	auth := HostAuth{User: config.User, Pass: config.Pass, BearerToken: config.BearerToken}
+	if hostAuth, found := config.hostAuth(req.URL); found {
+		auth = hostAuth
+		for name, value := range hostAuth.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+
+	if len(auth.BearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+	} else if len(auth.User) > 0 {
+		req.SetBasicAuth(auth.User, auth.Pass)
+	}
+}
+
+// hostAuth returns the credentials of the host of the URL, looked up with its
+// port first, and whether the host has its own
+func (config HTTPConfig) hostAuth(target *url.URL) (HostAuth, bool) {
+	if len(config.HostAuth) == 0 {
+		return HostAuth{}, false
+	}
+
+	if auth, found := config.HostAuth[target.Host]; found {
+		return auth, true
+	}
+
+	auth, found := config.HostAuth[target.Hostname()]
+	return auth, found
+}
+
+// checkAuth returns an error if more than one authentication scheme is
+// configured, globally or for a host
+func (config HTTPConfig) checkAuth() error {
+	if len(config.User) > 0 && len(config.BearerToken) > 0 {
+		return ErrConflictingAuth
+	}
+
+	for host, auth := range config.HostAuth {
+		if len(auth.User) > 0 && len(auth.BearerToken) > 0 {
+			return fmt.Errorf("%w for host %s", ErrConflictingAuth, host)
+		}
+	}
+
+	return nil
 }
 
-// HTTPGet issues a GET request to a single URL and returns an HTTPResponse
+// HTTPGet issues a GET request to a single URL and returns an HTTPResponse.
+// If config.Method is HEAD, a HEAD request is issued instead unless links are
+// parsed, falling back to GET if the server does not allow HEAD.
 func HTTPGet(urlStr string, config HTTPConfig) (response *HTTPResponse) {
+	method := config.requestMethod()
+
+	response = httpRequest(method, urlStr, config)
+	if method == http.MethodHead && response.StatusCode == http.StatusMethodNotAllowed {
+		config.logger().Debug("HEAD not allowed for "+urlStr+", falling back to GET", Fields{"url": urlStr})
+		response = httpRequest(http.MethodGet, urlStr, config)
+	}
+
+	response.Attempts = config.attempt
+	if !config.formatted {
+		printResult(response, config.logger())
+	}
+	return
+}
+
+func httpRequest(method string, urlStr string, config HTTPConfig) (response *HTTPResponse) {
 	response = &HTTPResponse{
 		URL: urlStr,
 	}
 
-	req, result, err := createRequest(urlStr)
+	ctx, cancel := requestContext(config)
+	defer cancel()
+
+	req, result, err := createRequest(ctx, method, urlStr)
 	if err != nil {
+		config.logger().Error(err.Error(), Fields{"url": urlStr})
 		response.Err = err
 		return
 	}
 
 	configureRequest(req, config)
+	req = req.WithContext(withAddressFamily(req.Context(), response))
 
-	client := http.Client{
-		Timeout: config.Timeout,
+	client, release, err := newClient(config)
+	if err != nil {
+		response.Err = err
+		return
 	}
+	defer release()
+	client.CheckRedirect = checkRedirect(config, response)
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	response.EndTime = time.Now()
+	response.elapsed = response.EndTime.Sub(start)
 	response.Response = resp
 	response.Result = result
 
@@ -83,38 +395,117 @@ func HTTPGet(urlStr string, config HTTPConfig) (response *HTTPResponse) {
 		response.StatusCode = 0
 	} else {
 		response.StatusCode = response.Response.StatusCode
+		response.FinalURL = resp.Request.URL.String()
+		response.ContentType = resp.Header.Get("Content-Type")
+		response.Proto = resp.Proto
+		response.Headers = captureHeaders(resp.Header, config.CaptureHeaders)
+		if config.CacheStatusHeader != "" {
+			response.CacheStatus = resp.Header.Get(config.CacheStatusHeader)
+		}
+		response.RetryAfter = parseRetryAfter(resp, response.EndTime)
+		if err == nil {
+			err = checkProtocol(config, resp)
+			if err != nil {
+				response.StatusCode = 0
+			}
+		}
 	}
 
-	defer func() {
-		if resp != nil && !config.ParseLinks {
-			io.Copy(ioutil.Discard, resp.Body)
-			resp.Body.Close()
-		}
-		PrintResult(response)
-	}()
+	if resp != nil {
+		defer resp.Body.Close()
+	}
 
 	if err != nil {
-		log.Error(err)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("request timed out after %v: %w", config.RequestTimeout, err)
+		}
+		config.logger().Error(err.Error(), Fields{"url": urlStr})
 		response.Err = err
 		return
 	}
 
+	body := newBodyReader(resp.Body, config.MaxBodyBytes)
+	var soft404Body *bytes.Buffer
+	if len(config.Soft404Patterns) > 0 && resp.StatusCode == http.StatusOK {
+		soft404Body = &bytes.Buffer{}
+		body.reader = io.TeeReader(body.reader, soft404Body)
+	}
+	defer func() {
+		response.BodySize = body.size
+		if body.err != nil {
+			response.Err = fmt.Errorf("failed to read body: %w", body.err)
+			response.ErrorKind = ErrorKindRead
+			config.logger().Error(response.Err.Error(), Fields{"url": urlStr})
+		}
+		if soft404Body != nil {
+			response.Soft404Match = matchSoft404(soft404Body.Bytes(), config.Soft404Patterns)
+		}
+	}()
+
 	if config.ParseLinks {
 		currentURL, err := url.Parse(urlStr)
 		if err != nil {
 			return
 		}
 
-		response.Links, err = ExtractLinks(resp.Body, *currentURL)
-		resp.Body.Close()
+		if extractor := config.linkExtractor(response.ContentType); extractor != nil {
+			response.Links, err = extractor(body, *currentURL)
+			if err != nil {
+				config.logger().Error(err.Error(), Fields{"url": urlStr})
+				response.addParseError(err, body)
+				return
+			}
+			resolveLinks(response.Links, *currentURL)
+			io.Copy(ioutil.Discard, body)
+			return
+		}
+
+		response.Links, response.anchors, response.ParseErrors, err = extractPage(ioutil.NopCloser(body),
+			*currentURL, config.checkFragments, config.logger())
 		if err != nil {
+			response.addParseError(err, body)
 			return
 		}
+	} else if !config.DiscardBody || soft404Body != nil {
+		io.Copy(ioutil.Discard, body)
 	}
 
 	return
 }
 
+// addParseError records an error parsing the body, unless caused by a read
+// error, which is the error of the response
+func (response *HTTPResponse) addParseError(err error, body *bodyReader) {
+	if body.err == nil {
+		response.ParseErrors = append(response.ParseErrors, err)
+	}
+}
+
+// bodyReader reads a response body up to an optional maximum size, and
+// counts the bytes read. err is the first read error but EOF.
+type bodyReader struct {
+	reader io.Reader
+	size   int64
+	err    error
+}
+
+func newBodyReader(body io.Reader, maxBytes int64) *bodyReader {
+	if maxBytes > 0 {
+		body = io.LimitReader(body, maxBytes)
+	}
+
+	return &bodyReader{reader: body}
+}
+
+func (body *bodyReader) Read(p []byte) (int, error) {
+	n, err := body.reader.Read(p)
+	body.size += int64(n)
+	if err != nil && err != io.EOF && body.err == nil {
+		body.err = err
+	}
+	return n, err
+}
+
 // ConcurrentHTTPGetter allows concurrent execution of an HTTPGetter
 type ConcurrentHTTPGetter interface {
 	ConcurrentHTTPGet(urls []string, config HTTPConfig, maxConcurrent int,
@@ -138,11 +529,21 @@ func (getter *BaseConcurrentHTTPGetter) ConcurrentHTTPGet(urls []string, config
 }
 
 // RunConcurrentGet runs multiple HTTP requests in parallel, and returns the
-// result in resultChan
+// result in resultChan. When a per host limit is configured, URLs of hosts at
+// their limit are postponed in favor of the next URLs. Within a crawl, more
+// URLs than maxConcurrent may be waiting for their turn, like robots.txt
+// delays, the rate limit or the cooldown of failing hosts, while at most
+// maxConcurrent requests are sent.
 func RunConcurrentGet(httpGet HTTPGetter, urls []string, config HTTPConfig,
 	maxConcurrent int, resultChan chan<- *HTTPResponse, quit <-chan struct{}) {
 
-	httpResources := make(chan int, maxConcurrent)
+	workers := config.workers
+	if workers <= 0 {
+		workers = maxConcurrent
+	}
+
+	httpResources := make(chan int, workers)
+	inFlight := make(chan int, maxConcurrent)
 	var wg sync.WaitGroup
 
 	defer func() {
@@ -150,10 +551,37 @@ func RunConcurrentGet(httpGet HTTPGetter, urls []string, config HTTPConfig,
 		close(resultChan)
 	}()
 
-	for _, url := range urls {
+	config.quit = quit
+
+	pending := urls
+	if config.hostThrottle != nil {
+		// URLs are dispatched out of order, work on a copy
+		pending = append([]string(nil), urls...)
+	}
+
+	for len(pending) > 0 {
+		index := config.hostThrottle.next(pending)
+		if index < 0 {
+			select {
+			case <-quit:
+				config.logger().Info("Waiting for workers to finish...", nil)
+				return
+			case <-config.hostThrottle.waitRelease():
+			}
+			continue
+		}
+
+		url := pending[index]
+		if index == 0 {
+			pending = pending[1:]
+		} else {
+			pending = append(pending[:index], pending[index+1:]...)
+		}
+
 		select {
 		case <-quit:
-			log.Info("Waiting for workers to finish...")
+			config.hostThrottle.release(url)
+			config.logger().Info("Waiting for workers to finish...", nil)
 			return
 		case httpResources <- 1:
 			wg.Add(1)
@@ -161,34 +589,128 @@ func RunConcurrentGet(httpGet HTTPGetter, urls []string, config HTTPConfig,
 			go func(url string) {
 				defer func() {
 					<-httpResources
+					config.hostThrottle.release(url)
 					wg.Done()
 				}()
 
-				resultChan <- httpGet(url, config)
+				config.window.wait(quit)
+				config.rampUp.wait(quit)
+				config.robots.wait(url, quit)
+				config.breaker.wait(url, quit)
+				config.rateLimiter.wait(quit)
+
+				select {
+				case <-quit:
+					return
+				case inFlight <- 1:
+				}
+				defer func() { <-inFlight }()
+
+				response := getWithRetry(httpGet, url, config, quit)
+				if response.ErrorKind == ErrorKindNone {
+					response.ErrorKind = classifyError(response)
+				}
+				config.breaker.record(url, response)
+				resultChan <- response
 			}(url)
 		}
 	}
 }
 
-// PrintResult will print information relative to the HTTPResponse
+func getWithRetry(httpGet HTTPGetter, url string, config HTTPConfig,
+	quit <-chan struct{}) (response *HTTPResponse) {
+
+	var retryAfterWaited time.Duration
+	for attempt := 1; ; attempt++ {
+		config.attempt = attempt
+		response = httpGet(url, config)
+		response.Attempts = attempt
+		response.RetryAfterWaited = retryAfterWaited
+
+		if attempt > config.Retry.MaxRetries || !config.Retry.shouldRetry(response) {
+			return
+		}
+
+		backoff, retryAfter := config.Retry.retryDelay(response, attempt)
+		if retryAfter {
+			retryAfterWaited += backoff
+		}
+		config.logger().Debug(fmt.Sprintf("Retrying %s in %v", url, backoff), Fields{
+			"url":         url,
+			"attempt":     attempt + 1,
+			"backoff_ms":  int(backoff / time.Millisecond),
+			"retry_after": retryAfter,
+		})
+
+		select {
+		case <-quit:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// captureHeaders returns the values of the named headers present, multiple
+// values being comma separated
+func captureHeaders(header http.Header, names []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	captured := make(map[string]string)
+	for _, name := range names {
+		if values := header.Values(name); len(values) > 0 {
+			captured[name] = strings.Join(values, ", ")
+		}
+	}
+
+	return captured
+}
+
+// PrintResult will print information relative to the HTTPResponse, as
+// structured 'url', 'status', 'duration_ms' and 'attempt' fields, plus timing
+// details in debug mode. Failed requests and error status codes are logged
+// as warnings.
 func PrintResult(result *HTTPResponse) {
-	total := int(result.Result.Total(result.EndTime).Round(time.Millisecond) / time.Millisecond)
-
-	if log.GetLevel() == log.DebugLevel {
-		log.WithFields(log.Fields{
-			"status":  result.StatusCode,
-			"dns":     int(result.Result.DNSLookup / time.Millisecond),
-			"tcpconn": int(result.Result.TCPConnection / time.Millisecond),
-			"tls":     int(result.Result.TLSHandshake / time.Millisecond),
-			"server":  int(result.Result.ServerProcessing / time.Millisecond),
-			"content": int(result.Result.ContentTransfer(result.EndTime) / time.Millisecond),
-			"time":    total,
-			"close":   result.EndTime,
-		}).Debug("url=" + result.URL)
-	} else {
-		log.WithFields(log.Fields{
-			"status":     result.StatusCode,
-			"total-time": total,
-		}).Info("url=" + result.URL)
+	printResult(result, defaultLogger)
+}
+
+func printResult(result *HTTPResponse, logger Logger) {
+	fields := Fields{
+		"url":    result.URL,
+		"status": result.StatusCode,
+	}
+	if result.Attempts > 0 {
+		fields["attempt"] = result.Attempts
+	}
+
+	logCrawled := logger.Info
+	if result.StatusCode == 0 || result.StatusCode >= 400 {
+		logCrawled = logger.Warn
 	}
+
+	if result.Result == nil {
+		// The request could not be created
+		logCrawled("crawled", fields)
+		return
+	}
+
+	fields["duration_ms"] = int(result.serverTime().Round(time.Millisecond) / time.Millisecond)
+	logCrawled("crawled", fields)
+
+	if !result.connected() {
+		return
+	}
+
+	logger.Debug("timings", Fields{
+		"url":     result.URL,
+		"dns":     int(result.Result.DNSLookup / time.Millisecond),
+		"tcpconn": int(result.Result.TCPConnection / time.Millisecond),
+		"tls":     int(result.Result.TLSHandshake / time.Millisecond),
+		"server":  int(result.Result.ServerProcessing / time.Millisecond),
+		"content": int(result.Result.ContentTransfer(result.EndTime) / time.Millisecond),
+		"size":    result.BodySize,
+		"proto":   result.Proto,
+		"close":   result.EndTime,
+	})
 }
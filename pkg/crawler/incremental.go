@@ -0,0 +1,167 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ParseLastMod parses a sitemap lastmod date, in one of the W3C datetime
+// formats like "2006-01-02" or "2006-01-02T15:04:05Z07:00"
+func ParseLastMod(lastMod string) (time.Time, error) {
+	parsed, valid := parseLastMod(lastMod)
+	if !valid {
+		return time.Time{}, fmt.Errorf("invalid date '%s', expected a W3C datetime like 2006-01-02", lastMod)
+	}
+
+	return parsed, nil
+}
+
+// ChangedSince returns the entries whose lastmod is after since. Entries
+// without a valid lastmod are kept, as they may have changed.
+func ChangedSince(entries []SitemapEntry, since time.Time) (changed []SitemapEntry) {
+	for _, entry := range entries {
+		lastMod, valid := parseLastMod(entry.LastMod)
+		if !valid || lastMod.After(since) {
+			changed = append(changed, entry)
+		}
+	}
+
+	return
+}
+
+// EntryUrls returns the URLs of the entries
+func EntryUrls(entries []SitemapEntry) []string {
+	urls := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		urls = append(urls, entry.Loc)
+	}
+
+	return urls
+}
+
+// LastModState holds the lastmod of the sitemap URLs crawled by previous
+// runs, to only crawl the URLs changed since
+type LastModState map[string]string
+
+// LoadLastModState reads a state saved by Save. A missing file holds an empty
+// state, every URL being considered changed.
+func LoadLastModState(path string) (LastModState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return make(LastModState), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	state := make(LastModState)
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lastmod state %s: %v", path, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the state to a file in JSON format
+func (state LastModState) Save(path string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Changed returns the entries absent from the state or with another lastmod.
+// Entries without lastmod are always considered changed.
+func (state LastModState) Changed(entries []SitemapEntry) (changed []SitemapEntry) {
+	for _, entry := range entries {
+		lastMod, found := state[entry.Loc]
+		if !found || entry.LastMod == "" || lastMod != entry.LastMod {
+			changed = append(changed, entry)
+		}
+	}
+
+	return
+}
+
+// Record stores the lastmod of the entries crawled with a 200 or 304 response
+// in the statistics. Failing entries are removed, to be crawled again by the next
+// run, while entries not crawled are left unchanged. Results are matched to
+// entries by URL, or by path and query if their origin was overridden.
+func (state LastModState) Record(entries []SitemapEntry, stats CrawlStats) {
+	results := make(map[string]CrawlResult)
+	for _, result := range stats.Results {
+		if result.Depth == 0 {
+			results[result.URL] = result
+			results[requestURI(result.URL)] = result
+		}
+	}
+
+	for _, entry := range entries {
+		result, found := results[entry.Loc]
+		if !found {
+			result, found = results[requestURI(entry.Loc)]
+		}
+
+		if !found {
+			continue
+		} else if (result.StatusCode != 200 && result.StatusCode != 304) || entry.LastMod == "" {
+			delete(state, entry.Loc)
+		} else {
+			state[entry.Loc] = entry.LastMod
+		}
+	}
+}
+
+// ModifiedSince returns the lastmod of the entries having a valid one, keyed
+// by URL, for HTTPConfig.IfModifiedSince
+func ModifiedSince(entries []SitemapEntry) map[string]time.Time {
+	modifiedSince := make(map[string]time.Time)
+	for _, entry := range entries {
+		if lastMod, err := ParseLastMod(entry.LastMod); err == nil {
+			modifiedSince[entry.Loc] = lastMod
+		}
+	}
+
+	return modifiedSince
+}
+
+// modifiedSince returns the If-Modified-Since date of the URL, if any
+func (config HTTPConfig) modifiedSince(rawURL string) (time.Time, bool) {
+	if len(config.IfModifiedSince) == 0 {
+		return time.Time{}, false
+	}
+
+	since, found := config.IfModifiedSince[rawURL]
+	if !found && config.ifModifiedSinceURIs != nil {
+		since, found = config.ifModifiedSinceURIs[requestURI(rawURL)]
+	}
+
+	return since, found
+}
+
+// byRequestURI returns the dates keyed by the path and query of their URL
+func byRequestURI(dates map[string]time.Time) map[string]time.Time {
+	byURI := make(map[string]time.Time, len(dates))
+	for rawURL, date := range dates {
+		byURI[requestURI(rawURL)] = date
+	}
+
+	return byURI
+}
+
+// requestURI returns the path and query of the URL, or the URL itself if it
+// cannot be parsed
+func requestURI(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return parsedURL.RequestURI()
+}
@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes to w a JUnit XML test suite with a test case per
+// crawled URL. URLs failing the FailurePolicy of config and content type
+// mismatches are failures, listing the pages linking to them.
+func WriteJUnitReport(w io.Writer, stats CrawlStats, config CrawlConfig) error {
+	failOn := config.FailurePolicy()
+	suite := junitTestSuite{
+		Name:  "crowlet",
+		Tests: len(stats.Results),
+		Time:  stats.WallClock.Seconds(),
+	}
+
+	for _, result := range stats.Results {
+		testCase := junitTestCase{
+			Name:      result.URL,
+			ClassName: "crowlet",
+			Time:      result.Time.Seconds(),
+			Failure:   newJUnitFailure(result, failOn),
+		}
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newJUnitFailure returns the failure of a crawl result, or nil if it
+// succeeded
+func newJUnitFailure(result CrawlResult, failOn StatusPolicy) *junitFailure {
+	var failure junitFailure
+	switch {
+	case failOn(result.StatusCode):
+		failure.Type = "status"
+		failure.Message = fmt.Sprintf("status code %d", result.StatusCode)
+		if result.Error != "" {
+			failure.Message += ": " + result.Error
+		}
+	case !result.hasExpectedContentType():
+		failure.Type = "content-type"
+		failure.Message = fmt.Sprintf("content type '%s', expected '%s'", result.ContentType,
+			result.ExpectedContentType)
+	case result.Soft404Match != "":
+		failure.Type = "soft-404"
+		failure.Message = fmt.Sprintf("body contains '%s'", result.Soft404Match)
+	default:
+		return nil
+	}
+
+	var body strings.Builder
+	for _, linkingURL := range result.LinkingURLs {
+		body.WriteString("linking-url: " + linkingURL + "\n")
+	}
+	failure.Body = body.String()
+
+	return &failure
+}
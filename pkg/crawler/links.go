@@ -1,12 +1,12 @@
 package crawler
 
 import (
+	"fmt"
 	"io"
 	"net/url"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	log "github.com/sirupsen/logrus"
 )
 
 // LinkType represent the type of link to crawl
@@ -17,6 +17,12 @@ const (
 	Hyperlink LinkType = 0
 	// Image is html 'img' tag
 	Image LinkType = 1
+	// Alternate is html 'link' tag with an 'alternate' or 'canonical' rel
+	Alternate LinkType = 2
+	// Stylesheet is html 'link' tag with a 'stylesheet' rel
+	Stylesheet LinkType = 3
+	// Script is html 'script' tag
+	Script LinkType = 4
 )
 
 // Link type holds information of URL links
@@ -27,18 +33,67 @@ type Link struct {
 	IsExternal bool
 }
 
+// ParseError is an error parsing a crawled page or one of its links, URL
+// being the page
+type ParseError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// linkErrors logs and collects the errors parsing the links of a page
+type linkErrors struct {
+	pageURL string
+	logger  Logger
+	errs    []error
+}
+
+func (linkErrs *linkErrors) add(err error) {
+	linkErrs.logger.Error(err.Error(), Fields{"url": linkErrs.pageURL})
+	linkErrs.errs = append(linkErrs.errs, err)
+}
+
 // ExtractLinks returns links found in the html page provided and currentURL.
 // The URL is used to differentiate between internal and external links
 func ExtractLinks(htmlBody io.ReadCloser, currentURL url.URL) ([]Link, error) {
+	return extractLinks(htmlBody, currentURL, defaultLogger)
+}
+
+func extractLinks(htmlBody io.ReadCloser, currentURL url.URL, logger Logger) ([]Link, error) {
+	links, _, _, err := extractPage(htmlBody, currentURL, false, logger)
+	return links, err
+}
+
+// extractPage returns the links found in the html page, its anchors if
+// withAnchors is set, and the errors parsing the links found, which are
+// skipped
+func extractPage(htmlBody io.ReadCloser, currentURL url.URL, withAnchors bool,
+	logger Logger) ([]Link, *pageAnchors, []error, error) {
+
 	doc, err := goquery.NewDocumentFromReader(htmlBody)
 	if err != nil {
-		log.Error(err)
-		return nil, err
+		logger.Error(err.Error(), Fields{"url": currentURL.String()})
+		return nil, nil, nil, err
+	}
+
+	var anchors *pageAnchors
+	if withAnchors {
+		anchors = extractAnchors(doc)
 	}
 
-	links := extractALinks(doc)
-	links = append(links, extractImageLinks(doc)...)
+	linkErrs := &linkErrors{pageURL: currentURL.String(), logger: logger}
+	links := extractALinks(doc, linkErrs)
+	links = append(links, extractImageLinks(doc, linkErrs)...)
+	links = append(links, extractAssetLinks(doc, "link[rel~=alternate], link[rel~=canonical]", "href", Alternate, linkErrs)...)
+	links = append(links, extractAssetLinks(doc, "link[rel~=stylesheet]", "href", Stylesheet, linkErrs)...)
+	links = append(links, extractAssetLinks(doc, "script", "src", Script, linkErrs)...)
+
+	resolveLinks(links, currentURL)
+	return links, anchors, linkErrs.errs, nil
+}
 
+// resolveLinks flags the links to other hosts as external, and resolves the
+// relative links against currentURL
+func resolveLinks(links []Link, currentURL url.URL) {
 	for index := range links {
 		links[index].IsExternal = links[index].TargetURL.IsAbs() &&
 			links[index].TargetURL.Host != currentURL.Host
@@ -47,19 +102,18 @@ func ExtractLinks(htmlBody io.ReadCloser, currentURL url.URL) ([]Link, error) {
 			links[index].TargetURL = *currentURL.ResolveReference(&links[index].TargetURL)
 		}
 	}
-	return links, nil
 }
 
-func extractALinks(doc *goquery.Document) (links []Link) {
+func extractALinks(doc *goquery.Document, linkErrs *linkErrors) (links []Link) {
 	doc.Find("a").Each(func(i int, s *goquery.Selection) {
 		targetURL, _ := s.Attr("href")
 
-		// TODO: Check that # exists
+		// Fragments of the same page are checked with the page anchors
 		if strings.HasPrefix(targetURL, "#") {
 			return
 		}
 
-		link := extractLink(targetURL)
+		link := extractLink(targetURL, linkErrs)
 		if link == nil {
 			return
 		}
@@ -71,7 +125,7 @@ func extractALinks(doc *goquery.Document) (links []Link) {
 	return
 }
 
-func extractImageLinks(doc *goquery.Document) (links []Link) {
+func extractImageLinks(doc *goquery.Document, linkErrs *linkErrors) (links []Link) {
 	doc.Find("img").Each(func(i int, s *goquery.Selection) {
 		targetURL, _ := s.Attr("src")
 
@@ -79,7 +133,7 @@ func extractImageLinks(doc *goquery.Document) (links []Link) {
 			return
 		}
 
-		link := extractLink(targetURL)
+		link := extractLink(targetURL, linkErrs)
 		if link == nil {
 			return
 		}
@@ -91,10 +145,43 @@ func extractImageLinks(doc *goquery.Document) (links []Link) {
 	return
 }
 
-func extractLink(urlString string) *Link {
+// extractAssetLinks returns the links of the given type found in the
+// attribute of the elements matching selector
+func extractAssetLinks(doc *goquery.Document, selector string, attribute string, linkType LinkType,
+	linkErrs *linkErrors) (links []Link) {
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+		targetURL, found := s.Attr(attribute)
+		if !found || targetURL == "" || strings.HasPrefix(targetURL, "data:") {
+			return
+		}
+
+		link := extractLink(targetURL, linkErrs)
+		if link == nil {
+			return
+		}
+
+		link.Type = linkType
+		links = append(links, *link)
+	})
+
+	return
+}
+
+// normalizeURL returns the URL without fragment, and with its query
+// parameters sorted by key
+func normalizeURL(targetURL url.URL) string {
+	targetURL.Fragment = ""
+	if targetURL.RawQuery != "" {
+		targetURL.RawQuery = targetURL.Query().Encode()
+	}
+
+	return targetURL.String()
+}
+
+func extractLink(urlString string, linkErrs *linkErrors) *Link {
 	url, err := url.Parse(urlString)
 	if err != nil {
-		log.Error(err)
+		linkErrs.add(fmt.Errorf("invalid link: %w", err))
 		return nil
 	}
 
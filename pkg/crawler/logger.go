@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Fields holds structured information attached to a log entry
+type Fields map[string]interface{}
+
+// Logger receives the logs of crawls. Fields may be nil.
+type Logger interface {
+	Debug(msg string, fields Fields)
+	Info(msg string, fields Fields)
+	Warn(msg string, fields Fields)
+	Error(msg string, fields Fields)
+}
+
+// logrusLogger is a Logger writing to a logrus logger
+type logrusLogger struct {
+	logger *log.Logger
+}
+
+// NewLogrusLogger returns a Logger writing to the given logrus logger, or to
+// the logrus standard logger if nil
+func NewLogrusLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+
+	return logrusLogger{logger: logger}
+}
+
+func (l logrusLogger) Debug(msg string, fields Fields) {
+	l.logger.WithFields(log.Fields(fields)).Debug(msg)
+}
+
+func (l logrusLogger) Info(msg string, fields Fields) {
+	l.logger.WithFields(log.Fields(fields)).Info(msg)
+}
+
+func (l logrusLogger) Warn(msg string, fields Fields) {
+	l.logger.WithFields(log.Fields(fields)).Warn(msg)
+}
+
+func (l logrusLogger) Error(msg string, fields Fields) {
+	l.logger.WithFields(log.Fields(fields)).Error(msg)
+}
+
+// defaultLogger is used when no Logger is configured
+var defaultLogger = NewLogrusLogger(nil)
+
+// logger returns the configured Logger, or the default one
+func (config CrawlConfig) logger() Logger {
+	if config.Logger == nil {
+		return defaultLogger
+	}
+
+	return config.Logger
+}
+
+// logger returns the configured Logger, or the default one
+func (config HTTPConfig) logger() Logger {
+	if config.Logger == nil {
+		return defaultLogger
+	}
+
+	return config.Logger
+}
+
+// LogLevel is the minimum level of the logs of a crawl passed to its Logger
+type LogLevel int
+
+const (
+	// LogDefault passes every log, leaving their filtering to the Logger
+	LogDefault LogLevel = iota
+	// LogDebug passes every log, including request timings and retries
+	LogDebug
+	// LogInfo passes the logs of each crawled URL and of the crawl progress
+	LogInfo
+	// LogWarn only passes the failing URLs, warnings and errors
+	LogWarn
+	// LogError only passes errors
+	LogError
+	// LogSilent discards every log
+	LogSilent
+)
+
+// ParseLogLevel parses a log level, one of "debug", "info", "warn", "error"
+// or "silent"
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LogDebug, nil
+	case "info":
+		return LogInfo, nil
+	case "warn", "warning":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	case "silent", "quiet":
+		return LogSilent, nil
+	}
+
+	return LogDefault, fmt.Errorf("invalid log level '%s', expected debug, info, warn, error or silent", level)
+}
+
+// levelLogger discards the logs below its level
+type levelLogger struct {
+	logger Logger
+	level  LogLevel
+}
+
+// withLevel returns the logger discarding the logs below the level, or the
+// logger itself for LogDefault
+func withLevel(logger Logger, level LogLevel) Logger {
+	if level == LogDefault {
+		return logger
+	}
+
+	return levelLogger{logger: logger, level: level}
+}
+
+func (l levelLogger) Debug(msg string, fields Fields) {
+	if l.level <= LogDebug {
+		l.logger.Debug(msg, fields)
+	}
+}
+
+func (l levelLogger) Info(msg string, fields Fields) {
+	if l.level <= LogInfo {
+		l.logger.Info(msg, fields)
+	}
+}
+
+func (l levelLogger) Warn(msg string, fields Fields) {
+	if l.level <= LogWarn {
+		l.logger.Warn(msg, fields)
+	}
+}
+
+func (l levelLogger) Error(msg string, fields Fields) {
+	if l.level <= LogError {
+		l.logger.Error(msg, fields)
+	}
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CrawlMetrics holds the Prometheus collectors updated as crawling results
+// arrive
+type CrawlMetrics struct {
+	requests     prometheus.Counter
+	statusCodes  *prometheus.CounterVec
+	responseTime prometheus.Histogram
+}
+
+// NewCrawlMetrics creates the crawling collectors and registers them in reg.
+// Buckets are the response time histogram buckets in seconds, defaulting to
+// prometheus.DefBuckets if empty. Collectors already registered in reg are
+// reused, so that several crawls can share a registry.
+func NewCrawlMetrics(reg prometheus.Registerer, buckets []float64) (*CrawlMetrics, error) {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	metrics := &CrawlMetrics{
+		requests: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "crowlet",
+			Name:      "requests_total",
+			Help:      "Total number of crawled URLs.",
+		}),
+		statusCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "crowlet",
+			Name:      "responses_total",
+			Help:      "Number of crawled URLs, by status code.",
+		}, []string{"code"}),
+		responseTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "crowlet",
+			Name:      "response_time_seconds",
+			Help:      "Total response time of crawled URLs.",
+			Buckets:   buckets,
+		}),
+	}
+
+	requests, err := registerCollector(reg, metrics.requests)
+	if err != nil {
+		return nil, err
+	}
+	statusCodes, err := registerCollector(reg, metrics.statusCodes)
+	if err != nil {
+		return nil, err
+	}
+	responseTime, err := registerCollector(reg, metrics.responseTime)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.requests = requests.(prometheus.Counter)
+	metrics.statusCodes = statusCodes.(*prometheus.CounterVec)
+	metrics.responseTime = responseTime.(prometheus.Histogram)
+
+	return metrics, nil
+}
+
+// registerCollector registers the collector in reg, or returns the
+// equivalent collector if already registered
+func registerCollector(reg prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(collector)
+	if registeredErr, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return registeredErr.ExistingCollector, nil
+	}
+
+	return collector, err
+}
+
+// observe updates the collectors with a single crawling result
+func (metrics *CrawlMetrics) observe(statusCode int, serverTime time.Duration) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.requests.Inc()
+	metrics.statusCodes.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+	metrics.responseTime.Observe(serverTime.Seconds())
+}
+
+// AsyncCrawlWithMetrics crawls URLs like AsyncCrawl, and records the results
+// as Prometheus metrics registered in reg, with the default buckets.
+func AsyncCrawlWithMetrics(urls []string, config CrawlConfig, quit <-chan struct{},
+	reg prometheus.Registerer) (stats CrawlStats, err error) {
+
+	config.Metrics, err = NewCrawlMetrics(reg, nil)
+	if err != nil {
+		return
+	}
+
+	return AsyncCrawl(urls, config, quit)
+}
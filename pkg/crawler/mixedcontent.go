@@ -0,0 +1,42 @@
+package crawler
+
+import (
+	"net/url"
+)
+
+// MixedContent is an insecure resource referenced from an https page
+type MixedContent struct {
+	SourceURL string `json:"source-url"`
+	TargetURL string `json:"target-url"`
+}
+
+// isSubresource returns whether links of this type are loaded by browsers
+// along with the page, as opposed to being navigated to
+func (linkType LinkType) isSubresource() bool {
+	return linkType == Image || linkType == Stylesheet || linkType == Script
+}
+
+// findMixedContent returns the http subresources referenced from the page,
+// if it was served over https
+func findMixedContent(result *HTTPResponse) (violations []MixedContent) {
+	pageURL := result.URL
+	if result.FinalURL != "" {
+		pageURL = result.FinalURL
+	}
+
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil || parsedURL.Scheme != "https" {
+		return nil
+	}
+
+	for _, link := range result.Links {
+		if link.Type.isSubresource() && link.TargetURL.Scheme == "http" {
+			violations = append(violations, MixedContent{
+				SourceURL: result.URL,
+				TargetURL: link.TargetURL.String(),
+			})
+		}
+	}
+
+	return violations
+}
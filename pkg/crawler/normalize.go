@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Normalizers holds the built-in URL normalizers by name, for
+// CrawlConfig.Normalize
+var Normalizers = map[string]func(*url.URL) *url.URL{
+	"strip-fragment": StripFragment,
+	"strip-utm":      StripUTMParams,
+}
+
+// StripFragment returns a copy of the URL without its fragment
+func StripFragment(u *url.URL) *url.URL {
+	stripped := *u
+	stripped.Fragment = ""
+	return &stripped
+}
+
+// StripUTMParams returns a copy of the URL without its utm_* tracking query
+// parameters, the other parameters keeping their order
+func StripUTMParams(u *url.URL) *url.URL {
+	stripped := *u
+	if stripped.RawQuery == "" {
+		return &stripped
+	}
+
+	var kept []string
+	for _, param := range strings.Split(stripped.RawQuery, "&") {
+		if !strings.HasPrefix(strings.ToLower(param), "utm_") {
+			kept = append(kept, param)
+		}
+	}
+	stripped.RawQuery = strings.Join(kept, "&")
+
+	return &stripped
+}
+
+// ChainNormalizers returns a normalizer applying the normalizers in order
+func ChainNormalizers(normalizers ...func(*url.URL) *url.URL) func(*url.URL) *url.URL {
+	return func(u *url.URL) *url.URL {
+		for _, normalize := range normalizers {
+			u = normalize(u)
+		}
+		return u
+	}
+}
+
+// ParseNormalizers returns the chain of the built-in normalizers named, or
+// nil if none is
+func ParseNormalizers(names []string) (func(*url.URL) *url.URL, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	normalizers := make([]func(*url.URL) *url.URL, 0, len(names))
+	for _, name := range names {
+		normalize, found := Normalizers[name]
+		if !found {
+			return nil, fmt.Errorf("unknown normalizer '%s', expected one of %s", name,
+				strings.Join(normalizerNames(), ", "))
+		}
+		normalizers = append(normalizers, normalize)
+	}
+
+	return ChainNormalizers(normalizers...), nil
+}
+
+func normalizerNames() []string {
+	names := make([]string, 0, len(Normalizers))
+	for name := range Normalizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// normalizeUrls applies the normalizer to the URLs, leaving the URLs that
+// cannot be parsed untouched
+func normalizeUrls(urls []string, normalize func(*url.URL) *url.URL, logger Logger) []string {
+	normalized := make([]string, 0, len(urls))
+	for _, rawURL := range urls {
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Failed to normalize %s: %v", rawURL, err), Fields{"url": rawURL})
+			normalized = append(normalized, rawURL)
+			continue
+		}
+
+		normalized = append(normalized, normalize(parsedURL).String())
+	}
+
+	return normalized
+}
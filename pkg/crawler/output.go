@@ -2,53 +2,215 @@ package crawler
 
 import (
 	"encoding/json"
+	"io"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type summary struct {
-	General          generalInfo      `json:"total"`
-	StatusInfo       statusInfo       `json:"status"`
-	ResponseTimeInfo responseTimeInfo `json:"response-time"`
+	General          generalInfo         `json:"total"`
+	StatusInfo       statusInfo          `json:"status"`
+	ResponseTimeInfo responseTimeInfo    `json:"response-time"`
+	Hosts            map[string]hostInfo `json:"hosts,omitempty"`
+	Cache            *cacheInfo          `json:"cache,omitempty"`
+}
+
+type cacheInfo struct {
+	Hits     int            `json:"hits"`
+	Misses   int            `json:"misses"`
+	Other    int            `json:"other"`
+	Statuses map[string]int `json:"statuses"`
+}
+
+type hostInfo struct {
+	Total         int         `json:"crawled"`
+	StatusCodes   map[int]int `json:"status-codes"`
+	AverageTimeMs int         `json:"avg-time-ms"`
+	MaxTimeMs     int         `json:"max-time-ms"`
 }
 
 type generalInfo struct {
-	Total int `json:"crawled"`
+	Total       int   `json:"crawled"`
+	TotalBytes  int64 `json:"total-bytes"`
+	NotModified int   `json:"not-modified,omitempty"`
+	WallClockMs int   `json:"wall-clock-ms"`
+	Stopped     bool  `json:"stopped,omitempty"`
 }
 
 type statusInfo struct {
-	StatusCodes map[int]int   `json:"status-codes"`
-	Non200Urls  []CrawlResult `json:"errors"`
+	StatusCodes           map[int]int       `json:"status-codes"`
+	StatusClasses         []StatusClass     `json:"status-classes,omitempty"`
+	ErrorKinds            map[ErrorKind]int `json:"error-kinds,omitempty"`
+	Non200Urls            []CrawlResult     `json:"errors"`
+	Redirects             []CrawlResult     `json:"redirects,omitempty"`
+	ContentTypeMismatches []CrawlResult     `json:"content-type-errors,omitempty"`
+	Soft404Urls           []CrawlResult     `json:"soft-404-errors,omitempty"`
+	EmptyBodyUrls         []CrawlResult     `json:"empty-body-errors,omitempty"`
+	RedirectedUrls        []CrawlResult     `json:"redirected-urls,omitempty"`
+	MixedContent          []MixedContent    `json:"mixed-content,omitempty"`
+	BrokenFragments       []BrokenFragment  `json:"broken-fragments,omitempty"`
+	ParseErrors           []ParseError      `json:"parse-errors,omitempty"`
 }
 
 type responseTimeInfo struct {
-	AverageTimeMs int `json:"avg-time-ms"`
-	MaxTimeMs     int `json:"max-time-ms"`
+	AverageTimeMs int           `json:"avg-time-ms"`
+	MaxTimeMs     int           `json:"max-time-ms"`
+	P50TimeMs     int           `json:"p50-time-ms"`
+	P95TimeMs     int           `json:"p95-time-ms"`
+	P99TimeMs     int           `json:"p99-time-ms"`
+	AverageTTFBMs int           `json:"avg-ttfb-ms"`
+	AverageDNSMs  int           `json:"avg-dns-ms"`
+	AverageConnMs int           `json:"avg-connect-ms"`
+	AverageTLSMs  int           `json:"avg-tls-ms"`
+	SlowUrls      []CrawlResult `json:"slow-urls,omitempty"`
 }
 
 // PrintJSONSummary prints a summary of HTTP response codes in JSON format
 func PrintJSONSummary(stats CrawlStats) {
-	summary := summary{
+	jsonSummary, err := json.Marshal(newSummary(stats))
+	if err != nil {
+		log.Error("Error generating JSON summary:", err)
+		return
+	}
+
+	println(string(jsonSummary))
+}
+
+// WriteStatsJSON writes the crawling statistics to w in JSON format, using the
+// same schema as PrintJSONSummary: totals, per status code counts, non-200
+// URLs with the pages linking to them, and 200 response times.
+func WriteStatsJSON(w io.Writer, stats CrawlStats) error {
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(newSummary(stats))
+}
+
+// SummarySchema is the version of the WriteSummary schema, increased on
+// incompatible changes
+const SummarySchema = 1
+
+type exitSummary struct {
+	Schema        int         `json:"schema"`
+	Passed        bool        `json:"passed"`
+	Error         string      `json:"error,omitempty"`
+	Total         int         `json:"crawled"`
+	Failures      int         `json:"failures"`
+	TotalBytes    int64       `json:"total-bytes"`
+	WallClockMs   int         `json:"wall-clock-ms"`
+	Stopped       bool        `json:"stopped"`
+	StatusCodes   map[int]int `json:"status-codes"`
+	AverageTimeMs int         `json:"avg-time-ms"`
+	MaxTimeMs     int         `json:"max-time-ms"`
+	P50TimeMs     int         `json:"p50-time-ms"`
+	P95TimeMs     int         `json:"p95-time-ms"`
+	P99TimeMs     int         `json:"p99-time-ms"`
+}
+
+// WriteSummary writes a single JSON object summarizing the crawl to w, for
+// scripts and dashboards: totals, counts per status code, response times,
+// and whether the crawl passed the checks of config, Failures counting the
+// URLs failing its FailurePolicy. Unlike WriteStatsJSON, URLs are not listed.
+// Its schema field is SummarySchema.
+func WriteSummary(w io.Writer, stats CrawlStats, config CrawlConfig) error {
+	statusCodes := stats.StatusCodes
+	if statusCodes == nil {
+		statusCodes = make(map[int]int)
+	}
+
+	exit := exitSummary{
+		Schema:        SummarySchema,
+		Passed:        true,
+		Total:         stats.Total,
+		Failures:      stats.Failures(config.FailurePolicy()),
+		TotalBytes:    stats.TotalBytes,
+		WallClockMs:   int(stats.WallClock / time.Millisecond),
+		Stopped:       stats.Stopped,
+		StatusCodes:   statusCodes,
+		AverageTimeMs: int(stats.Average200Time / time.Millisecond),
+		MaxTimeMs:     int(stats.Max200Time / time.Millisecond),
+		P50TimeMs:     int(stats.Percentile200Time(50) / time.Millisecond),
+		P95TimeMs:     int(stats.Percentile200Time(95) / time.Millisecond),
+		P99TimeMs:     int(stats.Percentile200Time(99) / time.Millisecond),
+	}
+	if err := checkStats(stats, config); err != nil {
+		exit.Passed = false
+		exit.Error = err.Error()
+	}
+
+	return json.NewEncoder(w).Encode(exit)
+}
+
+func newSummary(stats CrawlStats) summary {
+	var hosts map[string]hostInfo
+	if len(stats.ByHost) > 1 {
+		hosts = make(map[string]hostInfo, len(stats.ByHost))
+		for host, hostStats := range stats.ByHost {
+			hosts[host] = hostInfo{
+				Total:         hostStats.Total,
+				StatusCodes:   hostStats.StatusCodes,
+				AverageTimeMs: int(hostStats.Average200Time / time.Millisecond),
+				MaxTimeMs:     int(hostStats.Max200Time / time.Millisecond),
+			}
+		}
+	}
+
+	var cache *cacheInfo
+	if len(stats.Cache.Statuses) > 0 {
+		cache = &cacheInfo{
+			Hits:     stats.Cache.Hits,
+			Misses:   stats.Cache.Misses,
+			Other:    stats.Cache.Other,
+			Statuses: stats.Cache.Statuses,
+		}
+	}
+
+	phases := stats.Average200Phases()
+	return summary{
+		Hosts: hosts,
+		Cache: cache,
 		General: generalInfo{
-			Total: stats.Total,
+			Total:       stats.Total,
+			TotalBytes:  stats.TotalBytes,
+			NotModified: stats.NotModified,
+			WallClockMs: int(stats.WallClock / time.Millisecond),
+			Stopped:     stats.Stopped,
 		},
 		StatusInfo: statusInfo{
-			StatusCodes: stats.StatusCodes,
-			Non200Urls:  stats.Non200Urls,
+			StatusCodes:           stats.StatusCodes,
+			StatusClasses:         stats.StatusHistogram(),
+			ErrorKinds:            stats.ErrorKinds,
+			Non200Urls:            stats.Non200Urls,
+			Redirects:             stats.Redirects,
+			ContentTypeMismatches: stats.ContentTypeMismatches,
+			Soft404Urls:           stats.Soft404Urls,
+			EmptyBodyUrls:         stats.EmptyBodyUrls,
+			RedirectedUrls:        stats.RedirectedUrls,
+			MixedContent:          stats.MixedContent,
+			BrokenFragments:       stats.BrokenFragments,
+			ParseErrors:           stats.ParseErrors,
 		},
 		ResponseTimeInfo: responseTimeInfo{
 			AverageTimeMs: int(stats.Average200Time / time.Millisecond),
 			MaxTimeMs:     int(stats.Max200Time / time.Millisecond),
+			P50TimeMs:     int(stats.Percentile200Time(50) / time.Millisecond),
+			P95TimeMs:     int(stats.Percentile200Time(95) / time.Millisecond),
+			P99TimeMs:     int(stats.Percentile200Time(99) / time.Millisecond),
+			AverageTTFBMs: int(phases.TTFB / time.Millisecond),
+			AverageDNSMs:  int(phases.DNS / time.Millisecond),
+			AverageConnMs: int(phases.Connect / time.Millisecond),
+			AverageTLSMs:  int(phases.TLS / time.Millisecond),
+			SlowUrls:      stats.SlowUrls,
 		}}
+}
 
-	jsonSummary, err := json.Marshal(summary)
-	if err != nil {
-		log.Error("Error generating JSON summary:", err)
-		return
+// PrintSlowest prints the n slowest crawled URLs, whatever their status code
+func PrintSlowest(stats CrawlStats, n int) {
+	log.Info("-------- Slowest -------")
+	for _, crawlResult := range stats.TopSlowest(n) {
+		log.Info("    - ", crawlResult.URL, ": ", int(crawlResult.Time/time.Millisecond), "ms, status ",
+			crawlResult.StatusCode)
 	}
-
-	println(string(jsonSummary))
+	log.Info("------------------------")
 }
 
 // PrintSummary prints a summary of HTTP response codes
@@ -56,12 +218,36 @@ func PrintSummary(stats CrawlStats) {
 	log.Info("-------- Summary -------")
 	log.Info("general:")
 	log.Info("    crawled: ", stats.Total)
+	log.Info("    total-bytes: ", stats.TotalBytes)
+	if stats.NotModified > 0 {
+		log.Info("    not-modified: ", stats.NotModified)
+	}
+	log.Info("    wall-clock: ", int(stats.WallClock/time.Millisecond), "ms")
+	if stats.Stopped {
+		log.Info("    stopped: true, results are partial")
+	}
 	log.Info("")
 	log.Info("status:")
 	for code, count := range stats.StatusCodes {
 		log.Info("    status-", code, ": ", count)
 	}
 
+	if len(stats.StatusCodes) > 0 {
+		log.Info("")
+		log.Info("status-histogram:")
+		for _, line := range stats.StatusHistogramBars(40) {
+			log.Info("    ", line)
+		}
+	}
+
+	if len(stats.ErrorKinds) > 0 {
+		log.Info("")
+		log.Info("error-kinds:")
+		for _, kind := range sortedErrorKinds(stats.ErrorKinds) {
+			log.Info("    ", kind, ": ", stats.ErrorKinds[kind])
+		}
+	}
+
 	log.Info("")
 	log.Info("status-errors-detail:")
 	if len(stats.Non200Urls) == 0 {
@@ -70,15 +256,145 @@ func PrintSummary(stats CrawlStats) {
 		for _, crawlResult := range stats.Non200Urls {
 			log.Info("    - ", crawlResult.URL, ":")
 			log.Info("        status-code: ", crawlResult.StatusCode)
+			if crawlResult.ErrorKind != ErrorKindNone {
+				log.Info("        error-kind: ", crawlResult.ErrorKind)
+			}
+			if len(crawlResult.RedirectChain) > 0 {
+				log.Info("        redirect-chain: ", crawlResult.RedirectChain, " -> ", crawlResult.FinalURL)
+			}
+			if crawlResult.Error != "" {
+				log.Info("        error: ", crawlResult.Error)
+			}
 			for _, linkingURL := range crawlResult.LinkingURLs {
 				log.Info("        linking-url: ", linkingURL)
 			}
 		}
 	}
 
+	if len(stats.Redirects) > 0 {
+		log.Info("")
+		log.Info("redirects-detail:")
+		for _, crawlResult := range stats.Redirects {
+			log.Info("    - ", crawlResult.URL, ":")
+			log.Info("        status-code: ", crawlResult.StatusCode)
+			for _, linkingURL := range crawlResult.LinkingURLs {
+				log.Info("        linking-url: ", linkingURL)
+			}
+		}
+	}
+
+	if len(stats.ContentTypeMismatches) > 0 {
+		log.Info("")
+		log.Info("content-type-errors-detail:")
+		for _, crawlResult := range stats.ContentTypeMismatches {
+			log.Info("    - ", crawlResult.URL, ":")
+			log.Info("        content-type: ", crawlResult.ContentType)
+			log.Info("        expected-content-type: ", crawlResult.ExpectedContentType)
+			for _, linkingURL := range crawlResult.LinkingURLs {
+				log.Info("        linking-url: ", linkingURL)
+			}
+		}
+	}
+
+	if len(stats.Soft404Urls) > 0 {
+		log.Info("")
+		log.Info("soft-404-errors-detail:")
+		for _, crawlResult := range stats.Soft404Urls {
+			log.Info("    - ", crawlResult.URL, ":")
+			log.Info("        soft-404-match: ", crawlResult.Soft404Match)
+			for _, linkingURL := range crawlResult.LinkingURLs {
+				log.Info("        linking-url: ", linkingURL)
+			}
+		}
+	}
+
+	if len(stats.EmptyBodyUrls) > 0 {
+		log.Info("")
+		log.Info("empty-body-errors-detail:")
+		for _, crawlResult := range stats.EmptyBodyUrls {
+			log.Info("    - ", crawlResult.URL, ":")
+			for _, linkingURL := range crawlResult.LinkingURLs {
+				log.Info("        linking-url: ", linkingURL)
+			}
+		}
+	}
+
+	if len(stats.RedirectedUrls) > 0 {
+		log.Info("")
+		log.Info("redirected-urls-detail:")
+		for _, crawlResult := range stats.RedirectedUrls {
+			log.Info("    - ", crawlResult.URL, ":")
+			log.Info("        redirect-chain: ", crawlResult.RedirectChain, " -> ", crawlResult.FinalURL)
+		}
+	}
+
+	if len(stats.MixedContent) > 0 {
+		log.Info("")
+		log.Info("mixed-content-detail:")
+		for _, violation := range stats.MixedContent {
+			log.Info("    - ", violation.TargetURL, ":")
+			log.Info("        linking-url: ", violation.SourceURL)
+		}
+	}
+
+	if len(stats.BrokenFragments) > 0 {
+		log.Info("")
+		log.Info("broken-fragments-detail:")
+		for _, broken := range stats.BrokenFragments {
+			log.Info("    - ", broken.TargetURL, "#", broken.Fragment, ":")
+			log.Info("        linking-url: ", broken.SourceURL)
+		}
+	}
+
+	if len(stats.ParseErrors) > 0 {
+		log.Info("")
+		log.Info("parse-errors-detail:")
+		for _, parseErr := range stats.ParseErrors {
+			log.Info("    - ", parseErr.URL, ": ", parseErr.Error)
+		}
+	}
+
 	log.Info("")
 	log.Info("server-time: ")
 	log.Info("    avg-time: ", int(stats.Average200Time/time.Millisecond), "ms")
 	log.Info("    max-time: ", int(stats.Max200Time/time.Millisecond), "ms")
+	log.Info("    p50-time: ", int(stats.Percentile200Time(50)/time.Millisecond), "ms")
+	log.Info("    p95-time: ", int(stats.Percentile200Time(95)/time.Millisecond), "ms")
+	log.Info("    p99-time: ", int(stats.Percentile200Time(99)/time.Millisecond), "ms")
+	phases := stats.Average200Phases()
+	log.Info("    avg-ttfb: ", int(phases.TTFB/time.Millisecond), "ms")
+	log.Info("    avg-dns: ", int(phases.DNS/time.Millisecond), "ms")
+	log.Info("    avg-connect: ", int(phases.Connect/time.Millisecond), "ms")
+	log.Info("    avg-tls: ", int(phases.TLS/time.Millisecond), "ms")
+
+	if len(stats.Cache.Statuses) > 0 {
+		log.Info("")
+		log.Info("cache:")
+		log.Info("    hits: ", stats.Cache.Hits)
+		log.Info("    misses: ", stats.Cache.Misses)
+		log.Info("    other: ", stats.Cache.Other)
+		log.Info("    hit-ratio: ", int(stats.Cache.HitRatio()*100), "%")
+	}
+
+	if len(stats.ByHost) > 1 {
+		log.Info("")
+		log.Info("hosts:")
+		for _, host := range sortedHosts(stats.ByHost) {
+			hostStats := stats.ByHost[host]
+			log.Info("    - ", host, ":")
+			log.Info("        crawled: ", hostStats.Total)
+			log.Info("        errors: ", len(hostStats.Non200Urls))
+			log.Info("        avg-time: ", int(hostStats.Average200Time/time.Millisecond), "ms")
+			log.Info("        max-time: ", int(hostStats.Max200Time/time.Millisecond), "ms")
+		}
+	}
+
+	if len(stats.SlowUrls) > 0 {
+		log.Info("")
+		log.Info("slow-urls-detail:")
+		for _, crawlResult := range stats.SlowUrls {
+			log.Info("    - ", crawlResult.URL, ": ", int(crawlResult.Time/time.Millisecond), "ms")
+		}
+	}
 	log.Info("------------------------")
 }
@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusPolicy reports whether a status code is considered a crawling failure
+type StatusPolicy func(statusCode int) bool
+
+// DefaultFailOn is the failure policy used when CrawlConfig.FailOn is not set,
+// considering any status code other than 200 as a failure, except 3xx
+// redirects
+func DefaultFailOn(statusCode int) bool {
+	return statusCode != 200 && !isRedirectStatus(statusCode)
+}
+
+// isRedirectStatus returns whether the status code is a 3xx redirect, which
+// responses are only crawled as such when not followed
+func isRedirectStatus(statusCode int) bool {
+	return statusCode >= 300 && statusCode < 400
+}
+
+// FailurePolicy returns the FailOn policy of the configuration, or if nil
+// DefaultFailOn, the SuccessCodes not being failures if set
+func (config CrawlConfig) FailurePolicy() StatusPolicy {
+	if config.FailOn != nil {
+		return config.FailOn
+	} else if config.SuccessCodes == nil {
+		return DefaultFailOn
+	}
+
+	successCodes := config.SuccessCodes
+	return func(statusCode int) bool {
+		return !successCodes[statusCode] && !isRedirectStatus(statusCode)
+	}
+}
+
+// isSuccess returns whether the status code is one of the SuccessCodes, or
+// 200 if not set
+func (config CrawlConfig) isSuccess(statusCode int) bool {
+	if config.SuccessCodes == nil {
+		return statusCode == 200
+	}
+
+	return config.SuccessCodes[statusCode]
+}
+
+// ParseStatusCodes parses a comma separated list of status codes, like
+// "200,201,204", into a set
+func ParseStatusCodes(spec string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code '%s'", part)
+		}
+		codes[code] = true
+	}
+
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no status code in '%s'", spec)
+	}
+
+	return codes, nil
+}
+
+// ParseStatusPolicy parses a comma separated list of status codes and status
+// classes, like "404,5xx", into a policy matching them. Status code 0 stands
+// for requests failing without response.
+func ParseStatusPolicy(spec string) (StatusPolicy, error) {
+	codes := make(map[int]bool)
+	classes := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class '%s'", part)
+			}
+			classes[class] = true
+			continue
+		}
+
+		code, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code '%s'", part)
+		}
+		codes[code] = true
+	}
+
+	return func(statusCode int) bool {
+		return codes[statusCode] || classes[statusCode/100]
+	}, nil
+}
+
+// Failures returns the number of crawled URLs failing the policy, or
+// DefaultFailOn if nil
+func (stats CrawlStats) Failures(policy StatusPolicy) (failures int) {
+	if policy == nil {
+		policy = DefaultFailOn
+	}
+
+	for statusCode, count := range stats.StatusCodes {
+		if policy(statusCode) {
+			failures += count
+		}
+	}
+
+	return
+}
+
+// ClientErrors returns the crawled URLs answered with a 4xx status code
+func (stats CrawlStats) ClientErrors() []CrawlResult {
+	return stats.non200UrlsInClass(4)
+}
+
+// ServerErrors returns the crawled URLs answered with a 5xx status code
+func (stats CrawlStats) ServerErrors() []CrawlResult {
+	return stats.non200UrlsInClass(5)
+}
+
+// ClientErrorCount returns the number of crawled URLs answered with a 4xx
+// status code
+func (stats CrawlStats) ClientErrorCount() int {
+	return stats.statusClassCount(4)
+}
+
+// ServerErrorCount returns the number of crawled URLs answered with a 5xx
+// status code
+func (stats CrawlStats) ServerErrorCount() int {
+	return stats.statusClassCount(5)
+}
+
+func (stats CrawlStats) non200UrlsInClass(class int) (results []CrawlResult) {
+	for _, result := range stats.Non200Urls {
+		if result.StatusCode/100 == class {
+			results = append(results, result)
+		}
+	}
+
+	return
+}
+
+func (stats CrawlStats) statusClassCount(class int) (count int) {
+	for statusCode, statusCount := range stats.StatusCodes {
+		if statusCode/100 == class {
+			count += statusCount
+		}
+	}
+
+	return
+}
+
+// failureLimit stops a crawl once a maximum number of URLs failed
+type failureLimit struct {
+	max      int
+	policy   StatusPolicy
+	stop     func()
+	failures int
+}
+
+func newFailureLimit(max int, policy StatusPolicy, stop func()) *failureLimit {
+	if policy == nil {
+		policy = DefaultFailOn
+	}
+
+	return &failureLimit{max: max, policy: policy, stop: stop}
+}
+
+// record counts the result if it is a failure, stopping the crawl when the
+// maximum is reached
+func (limit *failureLimit) record(result CrawlResult, logger Logger) {
+	if limit == nil || !limit.policy(result.StatusCode) {
+		return
+	}
+
+	limit.failures++
+	if limit.failures == limit.max {
+		logger.Warn(fmt.Sprintf("Stopping crawl after %d failures", limit.failures), Fields{"failures": limit.failures})
+		limit.stop()
+	}
+}
+
+// reached returns whether the crawl was stopped by the limit
+func (limit *failureLimit) reached() bool {
+	return limit != nil && limit.failures >= limit.max
+}
@@ -0,0 +1,46 @@
+package crawler
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when
+// HTTPConfig.MaxRedirects is not set, matching net/http
+const DefaultMaxRedirects = 10
+
+var (
+	// ErrTooManyRedirects is returned when a URL redirects more than
+	// HTTPConfig.MaxRedirects times
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrRedirectLoop is returned when a URL redirects to a URL already
+	// visited in its redirect chain
+	ErrRedirectLoop = errors.New("redirect loop")
+)
+
+// checkRedirect returns an http.Client CheckRedirect policy recording the
+// redirect chain in response, and stopping on loops or too long chains
+func checkRedirect(config HTTPConfig, response *HTTPResponse) func(*http.Request, []*http.Request) error {
+	maxRedirects := config.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if req.Response != nil {
+			response.RedirectChain = append(response.RedirectChain, req.Response.StatusCode)
+		}
+
+		for _, previous := range via {
+			if previous.URL.String() == req.URL.String() {
+				return ErrRedirectLoop
+			}
+		}
+
+		if len(via) > maxRedirects {
+			return ErrTooManyRedirects
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// brokenLink is a non-200 URL referenced by a source page
+type brokenLink struct {
+	Source string
+	Target CrawlResult
+}
+
+// brokenLinks returns the broken links of the crawl, sorted by source page
+// then target URL. Non-200 URLs not found through links are ignored.
+func brokenLinks(stats CrawlStats) []brokenLink {
+	var links []brokenLink
+	for _, result := range stats.Non200Urls {
+		for _, linkingURL := range result.LinkingURLs {
+			links = append(links, brokenLink{Source: linkingURL, Target: result})
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Target.URL < links[j].Target.URL
+	})
+
+	return links
+}
+
+// WriteBrokenLinksReport writes to w the pages linking to non-200 URLs,
+// grouped by linking page
+func WriteBrokenLinksReport(w io.Writer, stats CrawlStats) error {
+	currentSource := ""
+	for _, link := range brokenLinks(stats) {
+		if link.Source != currentSource {
+			currentSource = link.Source
+			if _, err := fmt.Fprintf(w, "%s:\n", currentSource); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "    - %s (status %d)\n", link.Target.URL, link.Target.StatusCode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteBrokenLinksCSV writes to w the pages linking to non-200 URLs in CSV
+// format, with a 'source,target,status' header
+func WriteBrokenLinksCSV(w io.Writer, stats CrawlStats) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"source", "target", "status"})
+	for _, link := range brokenLinks(stats) {
+		writer.Write([]string{link.Source, link.Target.URL, strconv.Itoa(link.Target.StatusCode)})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteHeadersCSV writes to w the captured headers of each crawled URL in
+// CSV format, sorted by URL, with a 'url,status' header followed by the
+// header names. Missing headers are left empty.
+func WriteHeadersCSV(w io.Writer, stats CrawlStats, headers []string) error {
+	results := make([]CrawlResult, len(stats.Results))
+	copy(results, stats.Results)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].URL < results[j].URL })
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"url", "status"}, headers...))
+	for _, result := range results {
+		row := []string{result.URL, strconv.Itoa(result.StatusCode)}
+		for _, header := range headers {
+			row = append(row, result.Headers[header])
+		}
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// CSVResultWriter writes crawl results in CSV format as they arrive, with
+// 'url,status,server-time-ms' columns. Its OnResult method can be used as
+// CrawlConfig.OnResult.
+type CSVResultWriter struct {
+	writer *csv.Writer
+}
+
+// NewCSVResultWriter returns a CSVResultWriter writing to w, after writing
+// the header row
+func NewCSVResultWriter(w io.Writer) (*CSVResultWriter, error) {
+	writer := &CSVResultWriter{writer: csv.NewWriter(w)}
+	writer.writer.Write([]string{"url", "status", "server-time-ms"})
+	writer.writer.Flush()
+
+	return writer, writer.writer.Error()
+}
+
+// OnResult writes and flushes the row of a crawl result
+func (writer *CSVResultWriter) OnResult(result CrawlResult, progress Progress) {
+	writer.writer.Write([]string{
+		result.URL,
+		strconv.Itoa(result.StatusCode),
+		strconv.FormatInt(int64(result.Time/time.Millisecond), 10),
+	})
+	writer.writer.Flush()
+}
+
+// Error returns the first error encountered while writing results
+func (writer *CSVResultWriter) Error() error {
+	return writer.writer.Error()
+}
+
+// NDJSONWriter writes crawl results as they arrive in newline delimited JSON,
+// one CrawlResult object per line, for log pipelines. Its OnResult method can
+// be used as CrawlConfig.OnResult.
+type NDJSONWriter struct {
+	encoder *json.Encoder
+	err     error
+}
+
+// NewNDJSONWriter returns an NDJSONWriter writing to w
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{encoder: json.NewEncoder(w)}
+}
+
+// OnResult writes the line of a crawl result. Nothing is written once an
+// error was encountered.
+func (writer *NDJSONWriter) OnResult(result CrawlResult, progress Progress) {
+	if writer.err != nil {
+		return
+	}
+
+	writer.err = writer.encoder.Encode(result)
+}
+
+// Error returns the first error encountered while writing results
+func (writer *NDJSONWriter) Error() error {
+	return writer.err
+}
@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResolveUrls returns the URLs AsyncCrawl crawls from the URLs passed, in the
+// same order and form: URLs are filtered with the include and exclude
+// patterns, their scheme and host are overridden if configured, they are
+// normalized by Normalize if set, duplicates are removed if DedupeUrls is
+// set, a random sample of SampleN URLs is kept if set, and only the first
+// MaxURLs URLs are kept if set.
+func ResolveUrls(urls []string, config CrawlConfig) ([]string, error) {
+	filteredUrls, err := FilterUrls(urls, config)
+	if err != nil {
+		return nil, err
+	}
+	if filtered := len(urls) - len(filteredUrls); filtered > 0 {
+		config.logger().Info(fmt.Sprintf("Filtered out %d URL(s), %d remaining", filtered, len(filteredUrls)), nil)
+	}
+	urls = filteredUrls
+
+	if config.Scheme != "" && config.Scheme != "http" && config.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme override '%s', expected http or https", config.Scheme)
+	}
+
+	host, err := parseHostOverride(config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Scheme != "" || host != "" {
+		urls = overrideOrigin(urls, config.Scheme, host, config.logger())
+	}
+
+	if config.Normalize != nil {
+		urls = normalizeUrls(urls, config.Normalize, config.logger())
+	}
+
+	if config.DedupeUrls {
+		uniqueUrls := dedupeUrls(urls)
+		if duplicates := len(urls) - len(uniqueUrls); duplicates > 0 {
+			config.logger().Info(fmt.Sprintf("Skipping %d duplicate URL(s)", duplicates), nil)
+		}
+		urls = uniqueUrls
+	}
+
+	if config.SampleN > 0 && len(urls) > config.SampleN {
+		config.logger().Info(fmt.Sprintf("Sampling %d of %d URL(s)", config.SampleN, len(urls)), nil)
+		urls = sampleUrls(urls, config.SampleN, config.SampleSeed)
+	}
+
+	if config.MaxURLs > 0 && len(urls) > config.MaxURLs {
+		config.logger().Info(fmt.Sprintf("Limiting crawl to the first %d of %d URL(s)", config.MaxURLs, len(urls)), nil)
+		urls = urls[:config.MaxURLs]
+	}
+
+	return urls, nil
+}
+
+// sampleUrls returns n URLs picked at random, in their original order. The
+// same seed always picks the same URLs, while a 0 seed picks different URLs
+// on every call.
+func sampleUrls(urls []string, n int, seed int64) []string {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	indexes := rand.New(rand.NewSource(seed)).Perm(len(urls))[:n]
+	sort.Ints(indexes)
+
+	sampled := make([]string, 0, n)
+	for _, index := range indexes {
+		sampled = append(sampled, urls[index])
+	}
+
+	return sampled
+}
+
+// parseHostOverride validates a host override, either a hostname or an IP
+// address, with an optional port. IPv6 addresses without port may omit their
+// brackets.
+func parseHostOverride(host string) (string, error) {
+	if host == "" {
+		return "", nil
+	}
+
+	if strings.Count(host, ":") > 1 && !strings.HasPrefix(host, "[") {
+		host = "[" + host + "]"
+	}
+
+	parsedURL, err := url.Parse("//" + host)
+	if err != nil || parsedURL.Host != host || parsedURL.Hostname() == "" {
+		return "", fmt.Errorf("invalid host override '%s', expected host or host:port", host)
+	}
+
+	return host, nil
+}
+
+// overrideOrigin replaces the scheme and host of the URLs, each being left
+// untouched if empty. The port of the URLs is kept when the host has none.
+// URLs that cannot be parsed are left untouched.
+func overrideOrigin(urls []string, scheme string, host string, logger Logger) []string {
+	overridden := make([]string, 0, len(urls))
+	for _, rawURL := range urls {
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Failed to override origin of %s: %v", rawURL, err), Fields{"url": rawURL})
+			overridden = append(overridden, rawURL)
+			continue
+		}
+
+		if scheme != "" {
+			parsedURL.Scheme = scheme
+		}
+		if host != "" {
+			parsedURL.Host = overridePort(host, parsedURL.Port())
+		}
+		overridden = append(overridden, parsedURL.String())
+	}
+
+	return overridden
+}
+
+// overridePort returns host with the given port, unless host has a port
+func overridePort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil || port == "" {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
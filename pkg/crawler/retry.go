@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetryStatusCodes are the status codes retried when
+// RetryConfig.StatusCodes is not set
+var DefaultRetryStatusCodes = []int{429, 502, 503, 504}
+
+// DefaultMaxRetryAfter caps the Retry-After delays when
+// RetryConfig.MaxRetryAfter is not set
+const DefaultMaxRetryAfter = time.Minute
+
+// RetryConfig holds the retry policy for failed requests. Requests are
+// retried on network errors, and on the status codes listed. The Retry-After
+// header of 429 and 503 responses replaces the backoff if longer, capped by
+// MaxRetryAfter, or DefaultMaxRetryAfter if 0.
+type RetryConfig struct {
+	MaxRetries    int
+	BaseBackoff   time.Duration
+	StatusCodes   []int
+	MaxRetryAfter time.Duration
+}
+
+func (config RetryConfig) shouldRetry(response *HTTPResponse) bool {
+	// A nil Result means the request could not even be created, retrying
+	// would not help
+	if response.Err != nil {
+		return response.Result != nil
+	}
+
+	statusCodes := config.StatusCodes
+	if statusCodes == nil {
+		statusCodes = DefaultRetryStatusCodes
+	}
+
+	for _, code := range statusCodes {
+		if response.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the delay before the retry following the given attempt,
+// doubling at each attempt, with +/-25% of random jitter
+func (config RetryConfig) backoff(attempt int) time.Duration {
+	delay := config.BaseBackoff << uint(attempt-1)
+	if delay <= 0 {
+		return 0
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay*3/4 + jitter
+}
+
+// retryDelay returns the delay before the retry following the given attempt,
+// and whether it is the Retry-After delay of the response
+func (config RetryConfig) retryDelay(response *HTTPResponse, attempt int) (time.Duration, bool) {
+	backoff := config.backoff(attempt)
+	if response.RetryAfter <= backoff {
+		return backoff, false
+	}
+
+	maxRetryAfter := config.MaxRetryAfter
+	if maxRetryAfter <= 0 {
+		maxRetryAfter = DefaultMaxRetryAfter
+	}
+
+	if response.RetryAfter > maxRetryAfter {
+		return maxRetryAfter, true
+	}
+
+	return response.RetryAfter, true
+}
+
+// parseRetryAfter returns the delay of a Retry-After header of a 429 or 503
+// response, given either in seconds or as an HTTP date, or 0 if absent
+func parseRetryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+
+	return date.Sub(now)
+}
@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsUserAgent is the user agent token looked up in robots.txt files, the
+// '*' group being used if absent
+const robotsUserAgent = "crowlet"
+
+// robotsRules holds the robots.txt rules applying to crowlet for a host
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+// allowed returns whether the path can be crawled. As per RFC 9309, the
+// longest matching rule wins, and allow wins on ties.
+func (rules *robotsRules) allowed(path string) bool {
+	if rules == nil {
+		return true
+	}
+
+	longestAllow := longestPrefixMatch(rules.allow, path)
+	longestDisallow := longestPrefixMatch(rules.disallow, path)
+
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+func longestPrefixMatch(prefixes []string, path string) int {
+	longest := -1
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			longest = len(prefix)
+		}
+	}
+
+	return longest
+}
+
+// parseRobotsTxt extracts the rules applying to crowlet from a robots.txt
+func parseRobotsTxt(reader io.Reader) *robotsRules {
+	groups := make(map[string]*robotsRules)
+	var currentAgents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		if key == "user-agent" {
+			if inRules {
+				currentAgents = nil
+				inRules = false
+			}
+
+			agent := strings.ToLower(value)
+			currentAgents = append(currentAgents, agent)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+			continue
+		}
+
+		inRules = true
+		for _, agent := range currentAgents {
+			rules := groups[agent]
+			switch key {
+			case "allow":
+				if value != "" {
+					rules.allow = append(rules.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					rules.disallow = append(rules.disallow, value)
+				}
+			case "crawl-delay":
+				delay, err := strconv.ParseFloat(value, 64)
+				if err == nil && delay > 0 {
+					rules.crawlDelay = time.Duration(delay * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if rules, ok := groups[robotsUserAgent]; ok {
+		return rules
+	}
+
+	return groups["*"]
+}
+
+// robotsTxtCache fetches and caches robots.txt rules per host for the
+// duration of a crawl, and paces requests to honor their crawl delays
+type robotsTxtCache struct {
+	client      *http.Client
+	userAgent   string
+	logger      Logger
+	mutex       sync.Mutex
+	rules       map[string]*robotsEntry
+	nextRequest map[string]time.Time
+}
+
+// robotsEntry holds the rules of a host, fetched once while the requests to
+// other hosts go on
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+func newRobotsTxtCache(config HTTPConfig) *robotsTxtCache {
+	// The transport of the crawl is already created, so this cannot fail
+	config.Jar = nil
+	client, _, _ := newClient(config)
+
+	return &robotsTxtCache{
+		client:      client,
+		userAgent:   config.UserAgent,
+		logger:      config.logger(),
+		rules:       make(map[string]*robotsEntry),
+		nextRequest: make(map[string]time.Time),
+	}
+}
+
+// rulesFor returns the rules of the URL's host, fetching its robots.txt on
+// first use. A missing or unreachable robots.txt allows everything.
+func (cache *robotsTxtCache) rulesFor(target *url.URL) *robotsRules {
+	key := target.Scheme + "://" + target.Host
+
+	cache.mutex.Lock()
+	entry, ok := cache.rules[key]
+	if !ok {
+		entry = &robotsEntry{}
+		cache.rules[key] = entry
+	}
+	cache.mutex.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = cache.fetch(key)
+	})
+
+	return entry.rules
+}
+
+// fetch gets and parses the robots.txt of the host at key, or returns nil if
+// missing or unreachable
+func (cache *robotsTxtCache) fetch(key string) (rules *robotsRules) {
+	resp, err := cache.get(key + "/robots.txt")
+	if err != nil {
+		cache.logger.Warn(fmt.Sprintf("Failed to get robots.txt for %s: %v", key, err), Fields{"host": key})
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		rules = parseRobotsTxt(resp.Body)
+	}
+
+	return
+}
+
+func (cache *robotsTxtCache) get(robotsURL string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cache.userAgent) > 0 {
+		req.Header.Set("User-Agent", cache.userAgent)
+	}
+
+	return cache.client.Do(req)
+}
+
+// allowed returns whether the robots.txt of the URL's host allows crawling it
+func (cache *robotsTxtCache) allowed(rawURL string) bool {
+	if cache == nil {
+		return true
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+
+	return cache.rulesFor(target).allowed(target.RequestURI())
+}
+
+// wait blocks until the crawl delay of the URL's host allows a new request,
+// or until quit is closed
+func (cache *robotsTxtCache) wait(rawURL string, quit <-chan struct{}) {
+	if cache == nil {
+		return
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	rules := cache.rulesFor(target)
+	if rules == nil || rules.crawlDelay == 0 {
+		return
+	}
+
+	cache.mutex.Lock()
+	now := time.Now()
+	next := cache.nextRequest[target.Host]
+	if next.Before(now) {
+		next = now
+	}
+	cache.nextRequest[target.Host] = next.Add(rules.crawlDelay)
+	cache.mutex.Unlock()
+
+	select {
+	case <-quit:
+	case <-time.After(next.Sub(now)):
+	}
+}
@@ -0,0 +1,504 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yterajima/go-sitemap"
+)
+
+const (
+	// MaxSitemapURLs is the maximum number of URLs, or sitemaps for an
+	// index, a sitemap may list per sitemaps.org
+	MaxSitemapURLs = 50000
+	// MaxSitemapBytes is the maximum uncompressed size of a sitemap per
+	// sitemaps.org
+	MaxSitemapBytes = 50 * 1024 * 1024
+)
+
+// SitemapLimitError is the error of a SitemapError for a sitemap exceeding
+// MaxSitemapURLs or MaxSitemapBytes, holding its measured size. Its URLs are
+// still returned, while search engines may ignore part of them.
+type SitemapLimitError struct {
+	URLs  int
+	Bytes int
+}
+
+func (e *SitemapLimitError) Error() string {
+	return fmt.Sprintf("sitemap exceeds the limits of %d URLs and %d bytes, with %d URLs and %d bytes",
+		MaxSitemapURLs, MaxSitemapBytes, e.URLs, e.Bytes)
+}
+
+// SitemapError holds the failure encountered while loading a single sitemap,
+// or while parsing one of its URL entries, Loc being the raw entry
+type SitemapError struct {
+	SitemapURL string
+	Loc        string
+	Err        error
+}
+
+func (e SitemapError) Error() string {
+	if e.Loc != "" {
+		return e.SitemapURL + ": invalid URL '" + e.Loc + "': " + e.Err.Error()
+	}
+
+	return e.SitemapURL + ": " + e.Err.Error()
+}
+
+// SitemapErrors aggregates the failures encountered while loading a tree of
+// sitemaps, the invalid URL entries skipped, and the sitemaps exceeding the
+// size limits. URLs from sitemaps that could be loaded are still returned
+// alongside it.
+type SitemapErrors []SitemapError
+
+func (e SitemapErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, sitemapErr := range e {
+		messages = append(messages, sitemapErr.Error())
+	}
+
+	invalid := len(e.InvalidURLs())
+	oversized := len(e.OversizedSitemaps())
+	var counts []string
+	if failed := len(e) - invalid - oversized; failed > 0 {
+		counts = append(counts, fmt.Sprintf("%d sitemap(s) could not be loaded", failed))
+	}
+	if invalid > 0 {
+		counts = append(counts, fmt.Sprintf("%d URL(s) were invalid", invalid))
+	}
+	if oversized > 0 {
+		counts = append(counts, fmt.Sprintf("%d sitemap(s) exceeded the size limits", oversized))
+	}
+
+	return strings.Join(counts, ", ") + ": " + strings.Join(messages, "; ")
+}
+
+// InvalidURLs returns the errors of the URL entries that could not be parsed
+func (e SitemapErrors) InvalidURLs() (invalid []SitemapError) {
+	for _, sitemapErr := range e {
+		if sitemapErr.Loc != "" {
+			invalid = append(invalid, sitemapErr)
+		}
+	}
+
+	return
+}
+
+// OversizedSitemaps returns the errors of the sitemaps exceeding the size
+// limits, their Err being a *SitemapLimitError
+func (e SitemapErrors) OversizedSitemaps() (oversized []SitemapError) {
+	for _, sitemapErr := range e {
+		var limitErr *SitemapLimitError
+		if errors.As(sitemapErr.Err, &limitErr) {
+			oversized = append(oversized, sitemapErr)
+		}
+	}
+
+	return
+}
+
+// SitemapLoader loads sitemaps with its settings: HTTP holds the settings
+// used to fetch them over HTTP/S, like authentication, user agent, headers,
+// timeout, proxy and TLS options, and its Logger, and Concurrency is the
+// number of child sitemaps of an index fetched at once, 1 if 0. The zero
+// value loads sitemaps with the default settings, like the package
+// functions.
+type SitemapLoader struct {
+	HTTP        HTTPConfig
+	Concurrency int
+}
+
+// GetSitemapUrlsRecursive returns all URLs found from the sitemap passed as
+// parameter, which can be a URL or a local file path, following nested
+// sitemap indexes up to maxDepth levels below it. A maxDepth of 1 matches the
+// behaviour of GetSitemapUrls. URLs are deduplicated, and sitemaps
+// referencing an already visited sitemap are not fetched again. The child
+// sitemaps of an index are fetched concurrently, as set by the Concurrency of
+// the loader, their URLs being returned in index order. Failures to load
+// child sitemaps do not abort the discovery, and are returned as
+// SitemapErrors along with the invalid URL entries and the sitemaps exceeding
+// the size limits.
+func (loader SitemapLoader) GetSitemapUrlsRecursive(sitemapURL string, maxDepth int) (urls []*url.URL, err error) {
+	tree := newSitemapTree(loader)
+	rootErr := tree.load(sitemapURL, 0, maxDepth)
+	if rootErr != nil {
+		return nil, rootErr
+	}
+
+	if len(tree.errors) > 0 {
+		err = tree.errors
+	}
+
+	return tree.urls, err
+}
+
+// GetSitemapEntriesRecursive returns all URL entries found from the sitemap
+// passed as parameter with their metadata, following nested sitemap indexes
+// like GetSitemapUrlsRecursive
+func (loader SitemapLoader) GetSitemapEntriesRecursive(sitemapURL string, maxDepth int) (entries []SitemapEntry,
+	err error) {
+	tree := newSitemapTree(loader)
+	rootErr := tree.load(sitemapURL, 0, maxDepth)
+	if rootErr != nil {
+		return nil, rootErr
+	}
+
+	if len(tree.errors) > 0 {
+		err = tree.errors
+	}
+
+	return tree.entries, err
+}
+
+// GetSitemapUrlsRecursiveAsStrings returns all URLs found as string, following
+// nested sitemap indexes like GetSitemapUrlsRecursive
+func (loader SitemapLoader) GetSitemapUrlsRecursiveAsStrings(sitemapURL string, maxDepth int) (urls []string,
+	err error) {
+	typedUrls, err := loader.GetSitemapUrlsRecursive(sitemapURL, maxDepth)
+	for _, url := range typedUrls {
+		urls = append(urls, url.String())
+	}
+
+	return
+}
+
+// GetSitemapUrlsRecursive is SitemapLoader.GetSitemapUrlsRecursive with the
+// default settings
+func GetSitemapUrlsRecursive(sitemapURL string, maxDepth int) (urls []*url.URL, err error) {
+	return SitemapLoader{}.GetSitemapUrlsRecursive(sitemapURL, maxDepth)
+}
+
+// GetSitemapEntriesRecursive is SitemapLoader.GetSitemapEntriesRecursive with
+// the default settings
+func GetSitemapEntriesRecursive(sitemapURL string, maxDepth int) (entries []SitemapEntry, err error) {
+	return SitemapLoader{}.GetSitemapEntriesRecursive(sitemapURL, maxDepth)
+}
+
+// GetSitemapUrlsRecursiveAsStrings is
+// SitemapLoader.GetSitemapUrlsRecursiveAsStrings with the default settings
+func GetSitemapUrlsRecursiveAsStrings(sitemapURL string, maxDepth int) (urls []string, err error) {
+	return SitemapLoader{}.GetSitemapUrlsRecursiveAsStrings(sitemapURL, maxDepth)
+}
+
+// sitemapTree collects the URLs of a tree of sitemaps
+type sitemapTree struct {
+	loader          SitemapLoader
+	visitedSitemaps map[string]bool
+	seenUrls        map[string]bool
+	urls            []*url.URL
+	entries         []SitemapEntry
+	errors          SitemapErrors
+}
+
+func newSitemapTree(loader SitemapLoader) *sitemapTree {
+	return &sitemapTree{
+		loader:          loader,
+		visitedSitemaps: make(map[string]bool),
+		seenUrls:        make(map[string]bool),
+	}
+}
+
+// load fetches a sitemap and collects its URLs. Errors on the root sitemap
+// are returned directly, while errors on child sitemaps are accumulated.
+func (tree *sitemapTree) load(sitemapURL string, depth int, maxDepth int) error {
+	if tree.visitedSitemaps[sitemapURL] {
+		tree.loader.HTTP.logger().Debug("Skipping already visited sitemap "+sitemapURL, Fields{"sitemap": sitemapURL})
+		return nil
+	}
+	tree.visitedSitemaps[sitemapURL] = true
+
+	data, err := fetchSitemap(sitemapURL, tree.loader.HTTP)
+	return tree.parse(sitemapURL, data, err, depth, maxDepth)
+}
+
+// parse collects the URLs of a fetched sitemap, loading the child sitemaps of
+// an index
+func (tree *sitemapTree) parse(sitemapURL string, data []byte, err error, depth int, maxDepth int) error {
+	if err != nil {
+		return tree.fail(sitemapURL, depth, err)
+	}
+
+	index, indexErr := sitemap.ParseIndex(data)
+	if indexErr == nil {
+		if depth >= maxDepth {
+			tree.loader.HTTP.logger().Warn("Maximum sitemap depth reached, ignoring index "+sitemapURL,
+				Fields{"sitemap": sitemapURL})
+			return nil
+		}
+
+		tree.checkLimits(sitemapURL, len(index.Sitemap), len(data))
+		children := make([]string, 0, len(index.Sitemap))
+		for _, child := range index.Sitemap {
+			children = append(children, strings.TrimSpace(child.Loc))
+		}
+		tree.loadChildren(children, depth+1, maxDepth)
+		return nil
+	}
+
+	urlSet, err := sitemap.Parse(data)
+	if err != nil {
+		return tree.fail(sitemapURL, depth, errors.New("URL is not a sitemap or sitemapindex"))
+	}
+	tree.checkLimits(sitemapURL, len(urlSet.URL), len(data))
+
+	for _, urlEntry := range urlSet.URL {
+		loc := strings.TrimSpace(urlEntry.Loc)
+		if tree.seenUrls[loc] {
+			continue
+		}
+
+		newURL, err := url.Parse(loc)
+		if err != nil {
+			tree.loader.HTTP.logger().Error(err.Error(), Fields{"sitemap": sitemapURL})
+			tree.errors = append(tree.errors, SitemapError{SitemapURL: sitemapURL, Loc: loc, Err: err})
+			continue
+		}
+
+		tree.seenUrls[loc] = true
+		tree.urls = append(tree.urls, newURL)
+		tree.entries = append(tree.entries, SitemapEntry{
+			Loc:        newURL.String(),
+			LastMod:    strings.TrimSpace(urlEntry.LastMod),
+			ChangeFreq: strings.TrimSpace(urlEntry.ChangeFreq),
+			Priority:   urlEntry.Priority,
+		})
+	}
+
+	return nil
+}
+
+// loadChildren fetches the child sitemaps of an index concurrently, then
+// collects their URLs in index order
+func (tree *sitemapTree) loadChildren(children []string, depth int, maxDepth int) {
+	var pending []string
+	for _, child := range children {
+		if tree.visitedSitemaps[child] {
+			tree.loader.HTTP.logger().Debug("Skipping already visited sitemap "+child, Fields{"sitemap": child})
+			continue
+		}
+		tree.visitedSitemaps[child] = true
+		pending = append(pending, child)
+	}
+
+	fetchSitemapsInOrder(pending, tree.loader, func(child string, data []byte, err error) {
+		tree.parse(child, data, err, depth, maxDepth)
+	})
+}
+
+type fetchedSitemap struct {
+	data []byte
+	err  error
+}
+
+// fetchSitemapsInOrder fetches up to the Concurrency of the loader sitemaps at
+// once, and passes them to handle in the order of sitemapURLs from the calling
+// goroutine. A sitemap is only fetched once fewer than Concurrency sitemaps
+// are fetched or waiting to be handled, bounding the memory used by early
+// completions.
+func fetchSitemapsInOrder(sitemapURLs []string, loader SitemapLoader, handle func(string, []byte, error)) {
+	concurrency := loader.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fetched := make([]chan fetchedSitemap, len(sitemapURLs))
+	for index := range fetched {
+		fetched[index] = make(chan fetchedSitemap, 1)
+	}
+
+	slots := make(chan struct{}, concurrency)
+	go func() {
+		for index, sitemapURL := range sitemapURLs {
+			slots <- struct{}{}
+			go func(index int, sitemapURL string) {
+				data, err := fetchSitemap(sitemapURL, loader.HTTP)
+				fetched[index] <- fetchedSitemap{data: data, err: err}
+			}(index, sitemapURL)
+		}
+	}()
+
+	for index, sitemapURL := range sitemapURLs {
+		result := <-fetched[index]
+		handle(sitemapURL, result.data, result.err)
+		<-slots
+	}
+}
+
+// checkLimits records an error if the sitemap exceeds the sitemaps.org limits
+func (tree *sitemapTree) checkLimits(sitemapURL string, urls int, bytes int) {
+	if urls <= MaxSitemapURLs && bytes <= MaxSitemapBytes {
+		return
+	}
+
+	err := &SitemapLimitError{URLs: urls, Bytes: bytes}
+	tree.loader.HTTP.logger().Warn(fmt.Sprintf("Sitemap %s: %v", sitemapURL, err),
+		Fields{"sitemap": sitemapURL, "urls": urls, "bytes": bytes})
+	tree.errors = append(tree.errors, SitemapError{SitemapURL: sitemapURL, Err: err})
+}
+
+func (tree *sitemapTree) fail(sitemapURL string, depth int, err error) error {
+	if depth == 0 {
+		tree.loader.HTTP.logger().Error(err.Error(), Fields{"sitemap": sitemapURL})
+		return err
+	}
+
+	tree.loader.HTTP.logger().Warn(fmt.Sprintf("Failed to load sitemap %s: %v", sitemapURL, err),
+		Fields{"sitemap": sitemapURL})
+	tree.errors = append(tree.errors, SitemapError{SitemapURL: sitemapURL, Err: err})
+	return nil
+}
+
+func init() {
+	// The options passed to sitemap.Get are the HTTPConfig of the loader
+	sitemap.SetFetch(func(URL string, options interface{}) ([]byte, error) {
+		config, _ := options.(HTTPConfig)
+		return fetchSitemap(URL, config)
+	})
+}
+
+// fetchSitemap returns the content of a sitemap, decompressed if it is
+// gzipped. The sitemap can be an HTTP/S URL, fetched using config, a file://
+// URL or a local path.
+func fetchSitemap(sitemapURL string, config HTTPConfig) ([]byte, error) {
+	var data []byte
+	var uncompressed bool
+	var err error
+
+	if path, isFile := sitemapFilePath(sitemapURL); isFile {
+		data, err = ioutil.ReadFile(path)
+	} else {
+		data, uncompressed, err = httpGetSitemap(sitemapURL, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isGzipped(sitemapURL, data, uncompressed) {
+		data, err = gunzip(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %v", err)
+		}
+	}
+
+	return data, nil
+}
+
+// sitemapFilePath returns the local path of the sitemap if it is a file://
+// URL or a path rather than a URL
+func sitemapFilePath(sitemapURL string) (string, bool) {
+	parsedURL, err := url.Parse(sitemapURL)
+	if err != nil {
+		return sitemapURL, true
+	}
+
+	switch parsedURL.Scheme {
+	case "file":
+		return parsedURL.Path, true
+	case "":
+		return sitemapURL, true
+	}
+
+	return "", false
+}
+
+func httpGetSitemap(sitemapURL string, config HTTPConfig) (data []byte, uncompressed bool, err error) {
+	req, err := http.NewRequest("GET", sitemapURL, nil)
+	if err != nil {
+		return
+	}
+	configureRequest(req, config)
+
+	client, release, err := newClient(config)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	return data, resp.Uncompressed, err
+}
+
+// isGzipped returns whether the sitemap data is gzip compressed, based on the
+// gzip magic number or the URL suffix. Responses with a gzip Content-Encoding
+// are transparently decompressed by net/http, and flagged as uncompressed.
+func isGzipped(sitemapURL string, data []byte, uncompressed bool) bool {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		return true
+	}
+
+	parsedURL, err := url.Parse(sitemapURL)
+	return !uncompressed && err == nil && strings.HasSuffix(parsedURL.Path, ".gz")
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+// FindDuplicateSitemapUrls returns the URLs listed more than once in the
+// sitemap passed as parameter, with the number of times they are listed.
+// Like GetSitemapUrls, only sitemaps directly listed are looked into.
+func (loader SitemapLoader) FindDuplicateSitemapUrls(sitemapURL string) (map[string]int, error) {
+	sitemap, err := sitemap.Get(sitemapURL, loader.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, urlEntry := range sitemap.URL {
+		counts[strings.TrimSpace(urlEntry.Loc)]++
+	}
+
+	duplicates := make(map[string]int)
+	for loc, count := range counts {
+		if count > 1 {
+			duplicates[loc] = count
+		}
+	}
+
+	return duplicates, nil
+}
+
+// FindDuplicateSitemapUrls is SitemapLoader.FindDuplicateSitemapUrls with the
+// default settings
+func FindDuplicateSitemapUrls(sitemapURL string) (map[string]int, error) {
+	return SitemapLoader{}.FindDuplicateSitemapUrls(sitemapURL)
+}
+
+// dedupeUrls returns the URLs without duplicates, keeping their first
+// occurrence order
+func dedupeUrls(urls []string) []string {
+	seen := make(map[string]bool, len(urls))
+	uniqueUrls := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		uniqueUrls = append(uniqueUrls, url)
+	}
+
+	return uniqueUrls
+}
@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yterajima/go-sitemap"
+)
+
+// SitemapEntry holds a URL entry of a sitemap along with its metadata
+type SitemapEntry struct {
+	Loc        string
+	LastMod    string
+	ChangeFreq string
+	Priority   float32
+}
+
+// SitemapWarning holds a sitemap metadata field search engines would ignore,
+// Field being lastmod, changefreq or priority
+type SitemapWarning struct {
+	Loc     string
+	Field   string
+	Value   string
+	Message string
+}
+
+func (w SitemapWarning) String() string {
+	return fmt.Sprintf("%s: %s '%s' %s", w.Loc, w.Field, w.Value, w.Message)
+}
+
+// lastModLayouts are the W3C datetime formats allowed for lastmod
+var lastModLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+var changeFreqs = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
+// GetSitemapEntries returns the URL entries of the sitemap passed as
+// parameter with their metadata. Like GetSitemapUrls, only sitemaps directly
+// listed are looked into.
+func (loader SitemapLoader) GetSitemapEntries(sitemapURL string) ([]SitemapEntry, error) {
+	sitemap, err := sitemap.Get(sitemapURL, loader.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]SitemapEntry, 0, len(sitemap.URL))
+	for _, urlEntry := range sitemap.URL {
+		entries = append(entries, SitemapEntry{
+			Loc:        strings.TrimSpace(urlEntry.Loc),
+			LastMod:    strings.TrimSpace(urlEntry.LastMod),
+			ChangeFreq: strings.TrimSpace(urlEntry.ChangeFreq),
+			Priority:   urlEntry.Priority,
+		})
+	}
+
+	return entries, nil
+}
+
+// ValidateSitemap returns warnings about the metadata of the sitemap entries:
+// lastmod dates that are invalid or in the future, unknown changefreq values
+// and priorities outside of 0.0 to 1.0
+func (loader SitemapLoader) ValidateSitemap(sitemapURL string) ([]SitemapWarning, error) {
+	entries, err := loader.GetSitemapEntries(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateSitemapEntries(entries, time.Now()), nil
+}
+
+// GetSitemapEntries is SitemapLoader.GetSitemapEntries with the default
+// settings
+func GetSitemapEntries(sitemapURL string) ([]SitemapEntry, error) {
+	return SitemapLoader{}.GetSitemapEntries(sitemapURL)
+}
+
+// ValidateSitemap is SitemapLoader.ValidateSitemap with the default settings
+func ValidateSitemap(sitemapURL string) ([]SitemapWarning, error) {
+	return SitemapLoader{}.ValidateSitemap(sitemapURL)
+}
+
+func validateSitemapEntries(entries []SitemapEntry, now time.Time) (warnings []SitemapWarning) {
+	for _, entry := range entries {
+		if entry.LastMod != "" {
+			lastMod, valid := parseLastMod(entry.LastMod)
+			if !valid {
+				warnings = append(warnings, SitemapWarning{Loc: entry.Loc, Field: "lastmod", Value: entry.LastMod,
+					Message: "is not a W3C datetime"})
+			} else if lastMod.After(now) {
+				warnings = append(warnings, SitemapWarning{Loc: entry.Loc, Field: "lastmod", Value: entry.LastMod,
+					Message: "is in the future"})
+			}
+		}
+
+		if entry.ChangeFreq != "" && !changeFreqs[entry.ChangeFreq] {
+			warnings = append(warnings, SitemapWarning{Loc: entry.Loc, Field: "changefreq", Value: entry.ChangeFreq,
+				Message: "is not a valid change frequency"})
+		}
+
+		if entry.Priority < 0 || entry.Priority > 1 {
+			warnings = append(warnings, SitemapWarning{Loc: entry.Loc, Field: "priority", Value: fmt.Sprint(entry.Priority),
+				Message: "is outside of 0.0 to 1.0"})
+		}
+	}
+
+	return
+}
+
+func parseLastMod(lastMod string) (time.Time, bool) {
+	for _, layout := range lastModLayouts {
+		parsed, err := time.Parse(layout, lastMod)
+		if err == nil {
+			return parsed, true
+		}
+	}
+
+	return time.Time{}, false
+}
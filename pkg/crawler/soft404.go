@@ -0,0 +1,18 @@
+package crawler
+
+import (
+	"bytes"
+)
+
+// matchSoft404 returns the first pattern found in the body, ignoring case, or
+// an empty string if none is found
+func matchSoft404(body []byte, patterns []string) string {
+	body = bytes.ToLower(body)
+	for _, pattern := range patterns {
+		if pattern != "" && bytes.Contains(body, bytes.ToLower([]byte(pattern))) {
+			return pattern
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostThrottle limits the number of concurrent requests to each host
+type hostThrottle struct {
+	maxPerHost int
+	mutex      sync.Mutex
+	inFlight   map[string]int
+	released   chan struct{}
+}
+
+func newHostThrottle(maxPerHost int) *hostThrottle {
+	return &hostThrottle{
+		maxPerHost: maxPerHost,
+		inFlight:   make(map[string]int),
+		released:   make(chan struct{}, 1),
+	}
+}
+
+func hostOf(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsedURL.Host
+}
+
+// next reserves a request slot for the first URL whose host is below its
+// limit, and returns its index, or -1 if all hosts are at their limit. On a
+// nil throttle, the first URL is always returned.
+func (throttle *hostThrottle) next(urls []string) int {
+	if throttle == nil {
+		return 0
+	}
+
+	throttle.mutex.Lock()
+	defer throttle.mutex.Unlock()
+
+	fullHosts := make(map[string]bool)
+	for index, url := range urls {
+		host := hostOf(url)
+		if fullHosts[host] {
+			continue
+		}
+
+		if throttle.inFlight[host] < throttle.maxPerHost {
+			throttle.inFlight[host]++
+			return index
+		}
+		fullHosts[host] = true
+	}
+
+	return -1
+}
+
+// release frees the request slot reserved for the URL
+func (throttle *hostThrottle) release(url string) {
+	if throttle == nil {
+		return
+	}
+
+	throttle.mutex.Lock()
+	throttle.inFlight[hostOf(url)]--
+	throttle.mutex.Unlock()
+
+	select {
+	case throttle.released <- struct{}{}:
+	default:
+	}
+}
+
+// waitRelease returns a channel receiving a value when a slot is released,
+// that never fires on a nil throttle
+func (throttle *hostThrottle) waitRelease() <-chan struct{} {
+	if throttle == nil {
+		return nil
+	}
+
+	return throttle.released
+}
+
+// rateLimiter paces requests using a token bucket
+type rateLimiter struct {
+	limiter *rate.Limiter
+}
+
+func newRateLimiter(maxRPS float64) *rateLimiter {
+	return &rateLimiter{limiter: rate.NewLimiter(rate.Limit(maxRPS), 1)}
+}
+
+// wait blocks until the rate allows a new request, or until quit is closed
+func (limiter *rateLimiter) wait(quit <-chan struct{}) {
+	if limiter == nil {
+		return
+	}
+
+	reservation := limiter.limiter.Reserve()
+	select {
+	case <-quit:
+		reservation.Cancel()
+	case <-time.After(reservation.Delay()):
+	}
+}
+
+// rampUp delays the first requests of a crawl, so that its concurrent
+// requests start evenly spread over an interval rather than all at once
+type rampUp struct {
+	start    time.Time
+	interval time.Duration
+	slots    int
+	mutex    sync.Mutex
+	started  int
+}
+
+func newRampUp(interval time.Duration, slots int) *rampUp {
+	return &rampUp{start: time.Now(), interval: interval, slots: slots}
+}
+
+// wait blocks until the start time of the next request, or until quit is
+// closed. Requests after the first slots ones wait for the end of the
+// interval, so that requests completing early do not start a burst.
+func (ramp *rampUp) wait(quit <-chan struct{}) {
+	if ramp == nil {
+		return
+	}
+
+	ramp.mutex.Lock()
+	slot := ramp.started
+	ramp.started++
+	ramp.mutex.Unlock()
+
+	if slot >= ramp.slots {
+		slot = ramp.slots
+	}
+
+	startAt := ramp.start.Add(ramp.interval * time.Duration(slot) / time.Duration(ramp.slots))
+	if !time.Now().Before(startAt) {
+		return
+	}
+
+	select {
+	case <-quit:
+	case <-time.After(time.Until(startAt)):
+	}
+}
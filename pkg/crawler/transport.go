@@ -0,0 +1,238 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrHTTP2NotNegotiated is returned when ForceHTTP2 is set and the server
+// answered using another protocol
+var ErrHTTP2NotNegotiated = errors.New("HTTP/2 was not negotiated")
+
+// hasTransportOptions returns whether the configuration requires a
+// transport other than http.DefaultTransport
+func (config HTTPConfig) hasTransportOptions() bool {
+	return config.ForceHTTP2 || config.DisableHTTP2 || config.MaxIdleConns > 0 ||
+		config.MaxIdleConnsPerHost > 0 || config.DisableKeepAlives || config.Proxy != "" ||
+		config.InsecureSkipVerify || config.CAFile != "" || len(config.HostResolve) > 0 ||
+		(config.DialNetwork != "" && config.DialNetwork != "tcp")
+}
+
+// parseProxyURL returns the proxy URL of the configuration, or nil if unset
+func parseProxyURL(config HTTPConfig) (*url.URL, error) {
+	if config.Proxy == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(config.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return proxyURL, nil
+	}
+
+	return nil, fmt.Errorf("unsupported proxy scheme '%s', expected http, https or socks5", proxyURL.Scheme)
+}
+
+// newTransport returns the transport to use for the configuration, or nil
+// if http.DefaultTransport can be used
+func newTransport(config HTTPConfig) (*http.Transport, error) {
+	if !config.hasTransportOptions() {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = config.DisableKeepAlives
+
+	proxyURL, err := parseProxyURL(config)
+	if err != nil {
+		return nil, err
+	} else if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport.TLSClientConfig, err = newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.HostResolve) > 0 || config.DialNetwork != "" {
+		transport.DialContext, err = resolvingDialContext(config.HostResolve, config.DialNetwork)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+
+	if config.ForceHTTP2 {
+		transport.ForceAttemptHTTP2 = true
+	}
+
+	if config.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return transport, nil
+}
+
+// resolvingDialContext returns a dial function connecting to the address
+// the dialed host is pinned to, if any, using dialNetwork if not empty.
+// Requests keep their Host header and TLS server name, which net/http derives
+// from the URL and not from the dialed address.
+func resolvingDialContext(overrides map[string]string,
+	dialNetwork string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+
+	switch dialNetwork {
+	case "", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported dial network '%s', expected tcp, tcp4 or tcp6", dialNetwork)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dialNetwork != "" {
+			network = dialNetwork
+		}
+		return dialer.DialContext(ctx, network, resolveAddress(overrides, addr))
+	}, nil
+}
+
+// withAddressFamily returns a context recording the address family of the
+// connection of a request in its response
+func withAddressFamily(ctx context.Context, response *HTTPResponse) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			response.AddressFamily = addressFamily(info.Conn.RemoteAddr())
+		},
+	})
+}
+
+// addressFamily returns "ipv4" or "ipv6" depending on the IP of the address,
+// or an empty string if it is not an IP address
+func addressFamily(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return ""
+	} else if tcpAddr.IP.To4() != nil {
+		return "ipv4"
+	}
+
+	return "ipv6"
+}
+
+// resolveAddress returns the address a host:port address is pinned to, looking
+// up the host:port first and then the host alone. The port is kept if the
+// override has none.
+func resolveAddress(overrides map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	target, found := overrides[addr]
+	if !found {
+		target, found = overrides[host]
+	}
+	if !found {
+		return addr
+	}
+
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+
+	return net.JoinHostPort(strings.Trim(target, "[]"), port)
+}
+
+// newTLSConfig returns the TLS configuration trusting the CA bundle of the
+// configuration in addition to the system ones, or nil if not customized
+func newTLSConfig(config HTTPConfig) (*tls.Config, error) {
+	if !config.InsecureSkipVerify && config.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+
+		tlsConfig.RootCAs, err = x509.SystemCertPool()
+		if err != nil || tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", config.CAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// newClient returns a client using the transport shared by the requests of
+// the crawl, or a new one if the request is not part of a crawl. The returned
+// function releases the connections of a new transport.
+func newClient(config HTTPConfig) (client *http.Client, release func(), err error) {
+	client = &http.Client{
+		Timeout: config.Timeout,
+		Jar:     config.Jar,
+	}
+	release = func() {}
+
+	transport := config.transport
+	if transport == nil {
+		transport, err = newTransport(config)
+		if err != nil {
+			return nil, release, err
+		}
+		if transport != nil {
+			release = transport.CloseIdleConnections
+		}
+	}
+
+	if transport != nil {
+		client.Transport = transport
+	}
+
+	return client, release, nil
+}
+
+// checkProtocol returns an error if the response protocol does not match the
+// configuration
+func checkProtocol(config HTTPConfig, resp *http.Response) error {
+	if config.ForceHTTP2 && resp.ProtoMajor != 2 {
+		return fmt.Errorf("%w, got %s", ErrHTTP2NotNegotiated, resp.Proto)
+	}
+
+	return nil
+}
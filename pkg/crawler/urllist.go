@@ -0,0 +1,40 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// GetUrlsFromReader returns the URLs read from r, one per line. Blank lines
+// and lines starting with '#' are ignored.
+func GetUrlsFromReader(r io.Reader) (urls []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		urls = append(urls, line)
+	}
+
+	return urls, scanner.Err()
+}
+
+// GetUrlsFromFile returns the URLs listed in the file at path like
+// GetUrlsFromReader, or in stdin if path is '-'
+func GetUrlsFromFile(path string) ([]string, error) {
+	if path == "-" {
+		return GetUrlsFromReader(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return GetUrlsFromReader(file)
+}
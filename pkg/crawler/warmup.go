@@ -0,0 +1,38 @@
+package crawler
+
+import "fmt"
+
+// warmUpUrls returns the first URL of each host, in the order of the URLs
+func warmUpUrls(urls []string) []string {
+	var firstUrls []string
+	hosts := make(map[string]bool)
+	for _, url := range urls {
+		host := hostOf(url)
+		if hosts[host] {
+			continue
+		}
+
+		hosts[host] = true
+		firstUrls = append(firstUrls, url)
+	}
+
+	return firstUrls
+}
+
+// warmUp sends a throwaway request to the first URL of each host, so that
+// cold starts do not skew the statistics of the crawl. Responses are neither
+// logged as crawled nor counted, and do not affect the circuit breaker.
+func warmUp(urls []string, config CrawlConfig, quit <-chan struct{}) {
+	urls = warmUpUrls(urls)
+	config.logger().Info(fmt.Sprintf("Warming up %d host(s)", len(urls)), nil)
+
+	httpConfig := config.HTTP
+	httpConfig.ParseLinks = false
+	httpConfig.formatted = true
+	httpConfig.rampUp = nil
+	httpConfig.breaker = nil
+
+	for response := range config.HTTPGetter.ConcurrentHTTPGet(urls, httpConfig, config.Throttle, quit) {
+		config.logger().Debug("Warmed up "+response.URL, Fields{"url": response.URL, "status": response.StatusCode})
+	}
+}
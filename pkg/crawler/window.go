@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeWindow is the time during which a crawl is allowed to send requests:
+// the Days of the week, every day if empty, from StartHour included to
+// EndHour excluded, in Location or local time if nil. A window whose
+// EndHour is before its StartHour spans midnight, its hours after midnight
+// belonging to the day it started, while equal hours allow the whole day.
+type TimeWindow struct {
+	Days      []time.Weekday
+	StartHour int
+	EndHour   int
+	Location  *time.Location
+}
+
+// Contains returns whether the window allows requests at the given time
+func (window TimeWindow) Contains(t time.Time) bool {
+	t = t.In(window.location())
+	hour := t.Hour()
+	day := t.Weekday()
+	switch {
+	case window.StartHour == window.EndHour:
+	case window.StartHour < window.EndHour:
+		if hour < window.StartHour || hour >= window.EndHour {
+			return false
+		}
+	case hour < window.EndHour:
+		// After midnight, the window started the day before
+		day = (day + 6) % 7
+	case hour < window.StartHour:
+		return false
+	}
+
+	if len(window.Days) == 0 {
+		return true
+	}
+
+	for _, allowed := range window.Days {
+		if allowed == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// next returns the start of the first hour after t allowed by the window,
+// or the zero time if it allows none
+func (window TimeWindow) next(t time.Time) time.Time {
+	t = t.In(window.location())
+	hour := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	for i := 0; i < 8*24; i++ {
+		hour = hour.Add(time.Hour)
+		if window.Contains(hour) {
+			return hour
+		}
+	}
+
+	return time.Time{}
+}
+
+func (window TimeWindow) location() *time.Location {
+	if window.Location == nil {
+		return time.Local
+	}
+
+	return window.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseTimeWindow parses a time window made of optional days followed by an
+// hour range, like "mon-fri 9-17", "sat,sun 0-24" or "22-6". Days are
+// separated by commas, and can be ranges wrapping over the week end, like
+// "fri-mon". The hours are local.
+func ParseTimeWindow(spec string) (*TimeWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid time window '%s', expected '[days] start-end'", spec)
+	}
+
+	window := &TimeWindow{}
+	if len(fields) == 2 {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		window.Days = days
+	}
+
+	hours := strings.SplitN(fields[len(fields)-1], "-", 2)
+	if len(hours) != 2 {
+		return nil, fmt.Errorf("invalid hour range '%s', expected 'start-end'", fields[len(fields)-1])
+	}
+
+	var err error
+	window.StartHour, err = parseHour(hours[0])
+	if err != nil {
+		return nil, err
+	}
+	window.EndHour, err = parseHour(hours[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// 0-24 allows the whole day
+	window.EndHour %= 24
+	if window.StartHour == 24 {
+		return nil, fmt.Errorf("invalid start hour 24")
+	}
+
+	return window, nil
+}
+
+func parseHour(spec string) (int, error) {
+	hour, err := strconv.Atoi(spec)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid hour '%s', expected 0 to 24", spec)
+	}
+
+	return hour, nil
+}
+
+// parseWeekdays parses comma separated days or day ranges, like "mon-fri,sun"
+func parseWeekdays(spec string) (days []time.Weekday, err error) {
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, found := weekdays[bounds[0]]
+		if !found {
+			return nil, fmt.Errorf("invalid day '%s', expected mon, tue, wed, thu, fri, sat or sun", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, found = weekdays[bounds[1]]
+			if !found {
+				return nil, fmt.Errorf("invalid day '%s', expected mon, tue, wed, thu, fri, sat or sun", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// windowGuard pauses the requests of a crawl outside its time window,
+// logging when the crawl pauses and resumes
+type windowGuard struct {
+	window TimeWindow
+	logger Logger
+	mutex  sync.Mutex
+	paused bool
+}
+
+func newWindowGuard(window TimeWindow, logger Logger) *windowGuard {
+	return &windowGuard{window: window, logger: logger}
+}
+
+// wait blocks until the time window allows requests, or until quit is closed
+func (guard *windowGuard) wait(quit <-chan struct{}) {
+	if guard == nil {
+		return
+	}
+
+	for {
+		now := time.Now()
+		if guard.window.Contains(now) {
+			guard.setPaused(false, time.Time{})
+			return
+		}
+
+		next := guard.window.next(now)
+		if next.IsZero() {
+			guard.logger.Error("The crawl time window allows no hour, not crawling", nil)
+			<-quit
+			return
+		}
+		guard.setPaused(true, next)
+
+		select {
+		case <-quit:
+			return
+		case <-time.After(next.Sub(now)):
+		}
+	}
+}
+
+// setPaused logs the pause of the crawl until next, or its resuming, when
+// the state changes
+func (guard *windowGuard) setPaused(paused bool, next time.Time) {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+
+	if guard.paused == paused {
+		return
+	}
+	guard.paused = paused
+
+	if paused {
+		guard.logger.Info("Outside the crawl time window, pausing until "+next.Format(time.RFC3339),
+			Fields{"resume_at": next.Format(time.RFC3339)})
+	} else {
+		guard.logger.Info("Inside the crawl time window, resuming crawl", nil)
+	}
+}
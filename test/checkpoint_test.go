@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestAsyncCrawlResumeFromCheckpoint(t *testing.T) {
+	var mutex sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, r.URL.Path)
+		mutex.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crowlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	checkpoint := filepath.Join(dir, "checkpoint.jsonl")
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Checkpoint: checkpoint,
+	}
+
+	crawler.AsyncCrawl([]string{server.URL + "/a", server.URL + "/missing"}, config, make(chan struct{}))
+
+	requested = nil
+	config.ResumeFrom = checkpoint
+	urls := []string{server.URL + "/a", server.URL + "/missing", server.URL + "/b"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+
+	if !testEq(requested, []string{"/b"}) {
+		t.Fatal("Expected only the new URL to be crawled, got", requested)
+	}
+
+	if stats.Total != 3 || stats.StatusCodes[200] != 2 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected resumed results to be reported, got", stats.Total, stats.StatusCodes)
+	}
+
+	content, err := ioutil.ReadFile(checkpoint)
+	if err != nil || strings.Count(string(content), "\n") != 3 {
+		t.Fatal("Expected 3 results in the checkpoint, got", string(content), err)
+	}
+}
+
+func TestAsyncCrawlDeadLetterWriter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Write([]byte(`<html><body><a href="/broken">broken</a><a href="/moved">moved</a></body></html>`))
+		case "/broken":
+			w.WriteHeader(http.StatusNotFound)
+		case "/moved":
+			w.WriteHeader(http.StatusMovedPermanently)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	config := crawler.CrawlConfig{
+		Throttle:         1,
+		HTTPGetter:       &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links:            crawler.CrawlLinksConfig{CrawlHyperlinks: true},
+		DeadLetterWriter: &output,
+	}
+
+	crawler.AsyncCrawl([]string{server.URL + "/page", server.URL + "/error"}, config, make(chan struct{}))
+
+	failed := make(map[string]crawler.CrawlResult)
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var result crawler.CrawlResult
+		if err := decoder.Decode(&result); err != nil {
+			t.Fatal("Expected a CrawlResult per line, got", err)
+		}
+		failed[result.URL] = result
+	}
+
+	if len(failed) != 2 || failed[server.URL+"/error"].StatusCode != 500 {
+		t.Fatal("Expected only the failing URLs to be written, got", failed)
+	}
+
+	broken := failed[server.URL+"/broken"]
+	if broken.StatusCode != 404 || !testEq(broken.LinkingURLs, []string{server.URL + "/page"}) {
+		t.Fatal("Expected the broken link to be written with its linking page, got", broken)
+	}
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestAsyncCrawlLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.Method != http.MethodPost || r.PostFormValue("password") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged-in", Path: "/private"})
+		case "/private/page":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "logged-in" {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		case "/public":
+			if _, err := r.Cookie("session"); err == nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			Login: &crawler.LoginConfig{
+				URL:  server.URL + "/login",
+				Form: url.Values{"password": {"secret"}},
+			},
+		},
+	}
+
+	urls := []string{server.URL + "/private/page", server.URL + "/public"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.StatusCodes[200] != 2 {
+		t.Fatal("Expected the session cookie to be sent within its path only, got", stats.Non200Urls, err)
+	}
+
+	config.HTTP.Login.Form.Set("password", "wrong")
+	_, err = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil {
+		t.Fatal("Expected a login failure")
+	}
+}
+
+func TestAsyncCrawlCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("token"); err != nil || cookie.Value != "value" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			Cookies: []*http.Cookie{
+				{Name: "token", Value: "value"},
+			},
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/page"}, config, make(chan struct{}))
+	if err != nil || stats.StatusCodes[200] != 1 {
+		t.Fatal("Expected the configured cookie to be sent, got", stats.Non200Urls, err)
+	}
+}
+
+func TestAsyncCrawlLoginExpectedCookie(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.Method != http.MethodGet || r.URL.Query().Get("password") != "secret" {
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged-in", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusFound)
+		case "/page":
+			if _, err := r.Cookie("session"); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			Login: &crawler.LoginConfig{
+				URL:            server.URL + "/login",
+				Method:         http.MethodGet,
+				Form:           url.Values{"password": {"secret"}},
+				ExpectedCookie: "session",
+			},
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/page"}, config, make(chan struct{}))
+	if err != nil || stats.StatusCodes[200] != 1 {
+		t.Fatal("Expected the session cookie set before the redirect to be sent, got", stats.Non200Urls, err)
+	}
+
+	config.HTTP.Login.Form.Set("password", "wrong")
+	stats, err = crawler.AsyncCrawl([]string{server.URL + "/page"}, config, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "'session'") || stats.Total != 0 {
+		t.Fatal("Expected the login to fail without the session cookie, got", err)
+	}
+}
@@ -1,6 +1,12 @@
 package crawler
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,6 +17,7 @@ func TestMergeCrawlStats(t *testing.T) {
 	statsA := crawler.CrawlStats{
 		Total:          10,
 		StatusCodes:    map[int]int{200: 10},
+		Successes:      10,
 		Average200Time: time.Duration(1) * time.Second,
 		Max200Time:     time.Duration(2) * time.Second,
 	}
@@ -18,6 +25,7 @@ func TestMergeCrawlStats(t *testing.T) {
 	statsB := crawler.CrawlStats{
 		Total:          6,
 		StatusCodes:    map[int]int{200: 2, 404: 4},
+		Successes:      2,
 		Average200Time: time.Duration(7) * time.Second,
 		Max200Time:     time.Duration(9) * time.Second,
 	}
@@ -45,3 +53,537 @@ func TestMergeCrawlStats(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestMergeCrawlStatsAverageWithout200(t *testing.T) {
+	empty := crawler.CrawlStats{}
+	if stats := crawler.MergeCrawlStats(empty, empty); stats.Average200Time != 0 {
+		t.Fatal("Invalid average 200 time of empty stats:", stats.Average200Time)
+	}
+
+	statsA := crawler.CrawlStats{
+		StatusCodes:    map[int]int{200: 4},
+		Successes:      4,
+		Average200Time: time.Duration(3) * time.Second,
+	}
+	if stats := crawler.MergeCrawlStats(statsA, empty); stats.Average200Time != statsA.Average200Time {
+		t.Fatal("Invalid average 200 time merged with empty stats:", stats.Average200Time)
+	}
+	if stats := crawler.MergeCrawlStats(empty, statsA); stats.Average200Time != statsA.Average200Time {
+		t.Fatal("Invalid average 200 time merged into empty stats:", stats.Average200Time)
+	}
+
+	// Inconsistent stats, with an average but no 200 response
+	statsB := crawler.CrawlStats{
+		StatusCodes:    map[int]int{404: 2},
+		Average200Time: time.Duration(5) * time.Second,
+	}
+	if stats := crawler.MergeCrawlStats(statsB, statsB); stats.Average200Time != 0 {
+		t.Fatal("Invalid average 200 time without 200 responses:", stats.Average200Time)
+	}
+	if stats := crawler.MergeCrawlStats(statsA, statsB); stats.Average200Time != statsA.Average200Time {
+		t.Fatal("Invalid average 200 time with mismatched counts:", stats.Average200Time)
+	}
+}
+
+func TestPercentile200Time(t *testing.T) {
+	statsA := crawler.CrawlStats{}
+	statsB := crawler.CrawlStats{}
+	for i := 1; i <= 100; i++ {
+		if i%2 == 0 {
+			statsA.Times200 = append(statsA.Times200, time.Duration(i)*time.Millisecond)
+		} else {
+			statsB.Times200 = append(statsB.Times200, time.Duration(i)*time.Millisecond)
+		}
+	}
+
+	stats := crawler.MergeCrawlStats(statsA, statsB)
+
+	if len(stats.Times200) != 100 {
+		t.Fatal("Invalid merged 200 times count", len(stats.Times200))
+	}
+
+	expected := map[float64]time.Duration{
+		50: 50 * time.Millisecond,
+		95: 95 * time.Millisecond,
+		99: 99 * time.Millisecond,
+	}
+	for percentile, value := range expected {
+		if stats.Percentile200Time(percentile) != value {
+			t.Fatal("Invalid p", percentile, ":", stats.Percentile200Time(percentile))
+		}
+	}
+
+	if (crawler.CrawlStats{}).Percentile200Time(50) != 0 {
+		t.Fatal("Expected 0 percentile without 200 responses")
+	}
+}
+
+func TestAsyncCrawlContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stats, _ := crawler.AsyncCrawlContext(ctx, []string{server.URL + "/1", server.URL + "/2"}, config)
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Crawl was not cancelled with its context")
+	}
+
+	if stats.Total > 1 {
+		t.Fatal("Expected no new request after cancellation, crawled", stats.Total)
+	}
+}
+
+func TestAsyncCrawlOnResult(t *testing.T) {
+	var progresses []crawler.Progress
+	config := crawler.CrawlConfig{
+		Throttle: 2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				return &crawler.HTTPResponse{URL: url, StatusCode: 200}
+			},
+		},
+		OnResult: func(result crawler.CrawlResult, progress crawler.Progress) {
+			progresses = append(progresses, progress)
+		},
+	}
+
+	crawler.AsyncCrawl([]string{"1", "2", "3"}, config, make(chan struct{}))
+
+	if len(progresses) != 3 {
+		t.Fatal("Expected 3 results, got", len(progresses))
+	}
+
+	for i, progress := range progresses {
+		if progress.Completed != i+1 || progress.Total != 3 {
+			t.Fatal("Invalid progress", progress)
+		}
+	}
+}
+
+func TestAsyncCrawlDedupeUrls(t *testing.T) {
+	config := crawler.CrawlConfig{
+		Throttle: 2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				return &crawler.HTTPResponse{URL: url, StatusCode: 200}
+			},
+		},
+	}
+
+	urls := []string{"1", "2", "1", "3", "2"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if stats.Total != 5 {
+		t.Fatal("Expected duplicates to be crawled by default, got", stats.Total)
+	}
+
+	config.DedupeUrls = true
+	stats, _ = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if stats.Total != 3 {
+		t.Fatal("Expected 3 unique URLs crawled, got", stats.Total)
+	}
+}
+
+func TestAsyncCrawlSlowUrls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:      2,
+		HTTPGetter:    &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		SlowThreshold: 100 * time.Millisecond,
+	}
+
+	urls := []string{server.URL + "/fast", server.URL + "/slow"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || len(stats.SlowUrls) != 1 || stats.SlowUrls[0].URL != server.URL+"/slow" {
+		t.Fatal("Expected a single slow URL, got", stats.SlowUrls, err)
+	}
+
+	config.FailOnSlowUrls = 1
+	_, err = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil {
+		t.Fatal("Expected an error for slow URLs")
+	}
+}
+
+func TestAsyncCrawlWallClock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/page", server.URL + "/missing"}, config, make(chan struct{}))
+	if stats.WallClock < 100*time.Millisecond {
+		t.Fatal("Expected the wall clock time to cover both requests, got", stats.WallClock)
+	}
+
+	merged := crawler.MergeCrawlStats(stats, stats)
+	if merged.WallClock != 2*stats.WallClock {
+		t.Fatal("Expected merged wall clock times to be summed, got", merged.WallClock)
+	}
+}
+
+func TestAsyncCrawlWarmUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		WarmUp:     true,
+	}
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Total != 3 || atomic.LoadInt32(&requests) != 4 {
+		t.Fatal("Expected a single uncounted warm-up request, got", stats.Total, requests, err)
+	}
+
+	if stats.Max200Time >= 300*time.Millisecond || stats.WallClock >= 300*time.Millisecond {
+		t.Fatal("Expected the cold start to be left out of the statistics, got", stats.Max200Time, stats.WallClock)
+	}
+}
+
+func TestAsyncCrawlGracePeriod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/1", server.URL + "/2", server.URL + "/3"}
+	config := crawler.CrawlConfig{
+		Throttle:    2,
+		HTTPGetter:  &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		GracePeriod: time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stats, _ := crawler.AsyncCrawlContext(ctx, urls, config)
+	if !stats.Stopped || stats.Total != 2 || stats.StatusCodes[200] != 2 {
+		t.Fatal("Expected the in-flight requests to finish, and no new request, got", stats.StatusCodes, stats.Stopped)
+	}
+
+	config.GracePeriod = 10 * time.Millisecond
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stats, _ = crawler.AsyncCrawlContext(ctx, urls, config)
+	if time.Since(start) > 150*time.Millisecond || stats.StatusCodes[200] != 0 {
+		t.Fatal("Expected the in-flight requests to be aborted after the grace period, got", stats.StatusCodes)
+	}
+}
+
+func TestAsyncCrawlMaxCrawlDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 20; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:         1,
+		HTTPGetter:       &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		MaxCrawlDuration: 120 * time.Millisecond,
+	}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil || !stats.Stopped || stats.Total == 0 || stats.Total >= len(urls) {
+		t.Fatal("Expected partial stats after the maximum duration, got", stats.Total, stats.Stopped, err)
+	}
+
+	config.MaxCrawlDuration = time.Minute
+	stats, err = crawler.AsyncCrawl(urls[:2], config, make(chan struct{}))
+	if err != nil || stats.Stopped || stats.Total != 2 {
+		t.Fatal("Expected a complete crawl within the maximum duration, got", stats.Total, stats.Stopped, err)
+	}
+}
+
+func TestAsyncCrawlMaxFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 20; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:    1,
+		HTTPGetter:  &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		MaxFailures: 3,
+	}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "3 failures") || !stats.Stopped || stats.StatusCodes[404] < 3 ||
+		stats.Total >= len(urls) {
+		t.Fatal("Expected partial stats after 3 failures, got", stats.Total, stats.Stopped, err)
+	}
+
+	if stats.StatusCodes[200] != 1 {
+		t.Fatal("Expected successes not to count as failures, got", stats.StatusCodes)
+	}
+}
+
+func TestAverage200Phases(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{StatusCode: 200, Phases: crawler.PhaseTimes{DNS: 2 * time.Millisecond, TTFB: 10 * time.Millisecond}},
+			{StatusCode: 200, Phases: crawler.PhaseTimes{TLS: 4 * time.Millisecond, TTFB: 20 * time.Millisecond}},
+			{StatusCode: 404, Phases: crawler.PhaseTimes{TTFB: time.Second}},
+		},
+	}
+
+	expected := crawler.PhaseTimes{
+		DNS:  time.Millisecond,
+		TLS:  2 * time.Millisecond,
+		TTFB: 15 * time.Millisecond,
+	}
+	if phases := stats.Average200Phases(); phases != expected {
+		t.Fatal("Expected", expected, "but got", phases)
+	}
+
+	if (crawler.CrawlStats{}).Average200Phases() != (crawler.PhaseTimes{}) {
+		t.Fatal("Expected zero phases without 200 responses")
+	}
+}
+
+func TestAverage200PhasesSuccessCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:     1,
+		HTTPGetter:   &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		SuccessCodes: map[int]bool{204: true},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL}, config, make(chan struct{}))
+	if err != nil || stats.Average200Phases().TTFB == 0 {
+		t.Fatal("Expected the phases of the 204 success to be averaged, got", stats.Average200Phases(), err)
+	}
+
+	merged := crawler.MergeCrawlStats(crawler.CrawlStats{}, stats)
+	if merged.Average200Phases() != stats.Average200Phases() {
+		t.Fatal("Expected merged statistics to keep the success codes, got", merged.Average200Phases())
+	}
+}
+
+func TestAsyncCrawlPhases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if len(stats.Results) != 1 || stats.Results[0].Phases.TTFB < 20*time.Millisecond {
+		t.Fatal("Expected the time to first byte to be measured, got", stats.Results)
+	}
+}
+
+func TestAsyncCrawlByHost(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer okServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{okServer.URL + "/a", okServer.URL + "/b", failingServer.URL + "/c"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+
+	okHost := strings.TrimPrefix(okServer.URL, "http://")
+	failingHost := strings.TrimPrefix(failingServer.URL, "http://")
+	if len(stats.ByHost) != 2 ||
+		stats.ByHost[okHost].Total != 2 || stats.ByHost[okHost].StatusCodes[200] != 2 ||
+		stats.ByHost[failingHost].Total != 1 || stats.ByHost[failingHost].StatusCodes[500] != 1 {
+		t.Fatal("Expected stats grouped by host, got", stats.ByHost)
+	}
+
+	merged := crawler.MergeCrawlStats(stats, stats)
+	if merged.ByHost[okHost].Total != 4 || merged.ByHost[failingHost].Total != 2 {
+		t.Fatal("Expected per host stats to be merged, got", merged.ByHost)
+	}
+}
+
+func TestAsyncCrawlSortedNon200Urls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   4,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{server.URL + "/d", server.URL + "/b", server.URL + "/c", server.URL + "/a"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+
+	var crawled []string
+	for _, result := range stats.Non200Urls {
+		crawled = append(crawled, result.URL)
+	}
+
+	expected := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c", server.URL + "/d"}
+	if !testEq(crawled, expected) {
+		t.Fatal("Expected non-200 URLs sorted by URL, got", crawled)
+	}
+}
+
+func TestTopSlowest(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "/fast", StatusCode: 200, Time: 10 * time.Millisecond},
+			{URL: "/slow-error", StatusCode: 500, Time: 90 * time.Millisecond},
+			{URL: "/medium", StatusCode: 200, Time: 50 * time.Millisecond},
+			{URL: "/timeout", StatusCode: 0, Time: 30 * time.Second},
+		},
+	}
+
+	slowest := stats.TopSlowest(2)
+	if len(slowest) != 2 || slowest[0].URL != "/slow-error" || slowest[1].URL != "/medium" {
+		t.Fatal("Expected the 2 slowest URLs, got", slowest)
+	}
+
+	if len(stats.TopSlowest(10)) != 3 || stats.Results[0].URL != "/fast" {
+		t.Fatal("Expected every URL with a response when fewer than requested, without reordering the results")
+	}
+
+	if len(stats.TopSlowest(-1)) != 0 {
+		t.Fatal("Expected no URL for a negative count")
+	}
+}
+
+func TestAsyncCrawlPreserveOrder(t *testing.T) {
+	var crawled []string
+	config := crawler.CrawlConfig{
+		Throttle: 4,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				delay, _ := strconv.Atoi(url)
+				time.Sleep(time.Duration(delay) * time.Millisecond)
+				return &crawler.HTTPResponse{URL: url, StatusCode: 200}
+			},
+		},
+		OnResult: func(result crawler.CrawlResult, progress crawler.Progress) {
+			crawled = append(crawled, result.URL)
+		},
+		PreserveOrder: true,
+	}
+
+	urls := []string{"40", "10", "30", "10", "0"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || !testEq(crawled, urls) {
+		t.Fatal("Expected the results in URL order, got", crawled, err)
+	}
+
+	var results []string
+	for _, result := range stats.Results {
+		results = append(results, result.URL)
+	}
+	if !testEq(results, urls) {
+		t.Fatal("Expected the statistics results in URL order, got", results)
+	}
+}
+
+func TestAsyncCrawlFailOnEmptyBody(t *testing.T) {
+	getter := crawler.NewFakeHTTPGetter().
+		Respond("http://foo.bar/page", crawler.FakeResponse{StatusCode: 200, BodySize: 512}).
+		Respond("http://foo.bar/blank", crawler.FakeResponse{StatusCode: 200}).
+		Respond("http://foo.bar/deleted", crawler.FakeResponse{StatusCode: 204})
+
+	urls := []string{"http://foo.bar/page", "http://foo.bar/blank", "http://foo.bar/deleted"}
+	config := crawler.CrawlConfig{
+		Throttle:        1,
+		HTTPGetter:      getter,
+		SuccessCodes:    map[int]bool{200: true, 204: true},
+		FailOnEmptyBody: true,
+	}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil || len(stats.EmptyBodyUrls) != 1 || stats.EmptyBodyUrls[0].URL != "http://foo.bar/blank" {
+		t.Fatal("Expected the empty 200 response to fail the crawl, got", stats.EmptyBodyUrls, err)
+	}
+
+	config.HTTP.Method = http.MethodHead
+	stats, err = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || len(stats.EmptyBodyUrls) != 0 {
+		t.Fatal("Expected HEAD responses not to be checked, got", stats.EmptyBodyUrls, err)
+	}
+
+	config.HTTP.Method = ""
+	config.FailOnEmptyBody = false
+	if stats, err = crawler.AsyncCrawl(urls, config, make(chan struct{})); err != nil || len(stats.EmptyBodyUrls) != 0 {
+		t.Fatal("Expected empty bodies to be accepted by default, got", stats.EmptyBodyUrls, err)
+	}
+}
+
+func TestAsyncCrawlFailOnEmptyBodyDiscarded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>content</body></html>"))
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:        1,
+		HTTPGetter:      &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:            crawler.HTTPConfig{DiscardBody: true},
+		FailOnEmptyBody: true,
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL}, config, make(chan struct{}))
+	if err != nil || len(stats.EmptyBodyUrls) != 0 {
+		t.Fatal("Expected discarded bodies not to be checked, got", stats.EmptyBodyUrls, err)
+	}
+}
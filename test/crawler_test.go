@@ -0,0 +1,165 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestCrawlerStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/missing"}
+	c := crawler.NewCrawler(urls, config)
+	results := c.Results()
+	c.Start(make(chan struct{}))
+
+	count := 0
+	for result := range results {
+		count++
+		if stats := c.Snapshot(); stats.Total < count {
+			t.Fatal("Expected live stats to follow the results, got", stats.Total, "after", count, "result(s)")
+		}
+		if result.URL == "" {
+			t.Fatal("Expected results to hold their URL")
+		}
+	}
+
+	stats, err := c.Wait()
+	if err == nil {
+		t.Fatal("Expected the 404 to fail the crawl")
+	}
+	if count != 3 || stats.Total != 3 || stats.StatusCodes[404] != 1 {
+		t.Fatal("Expected 3 streamed results, got", count, stats)
+	}
+	if c.Snapshot().Total != 3 {
+		t.Fatal("Expected the final stats once done, got", c.Snapshot())
+	}
+}
+
+func TestAsyncCrawlStreamStopped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	var urls []string
+	for i := 0; i < 50; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+
+	quit := make(chan struct{})
+	results, c := crawler.AsyncCrawlStream(urls, config, quit)
+
+	count := 0
+	for range results {
+		count++
+		if count == 2 {
+			close(quit)
+		}
+	}
+
+	stats, _ := c.Wait()
+	if !stats.Stopped || count < 2 || count >= len(urls) {
+		t.Fatal("Expected the stream to close once stopped, got", count, "result(s)")
+	}
+}
+
+func TestCrawlerStatsWhileCrawling(t *testing.T) {
+	getter := crawler.NewFakeHTTPGetter()
+	getter.Default = &crawler.FakeResponse{StatusCode: 503, Delay: time.Millisecond}
+
+	var urls []string
+	for i := 0; i < 50; i++ {
+		urls = append(urls, "http://foo.bar/"+strconv.Itoa(i))
+	}
+
+	c := crawler.NewCrawler(urls, crawler.CrawlConfig{Throttle: 4, HTTPGetter: getter})
+	c.Start(make(chan struct{}))
+
+	// Run with -race to check the statistics are copied
+	done := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			stats := c.Snapshot()
+			count := 0
+			for _, kindCount := range stats.ErrorKinds {
+				count += kindCount
+			}
+			for _, statusCount := range stats.StatusCodes {
+				count -= statusCount
+			}
+			if count != 0 {
+				t.Error("Expected every result to be counted as an error, got", stats.ErrorKinds, stats.StatusCodes)
+				return
+			}
+		}
+	}()
+
+	stats, _ := c.Wait()
+	close(done)
+	<-polled
+	if stats.ErrorKinds[crawler.ErrorKindHTTPStatus] != len(urls) {
+		t.Fatal("Expected every result to be an HTTP status error, got", stats.ErrorKinds)
+	}
+}
+
+func TestCrawlerSnapshot(t *testing.T) {
+	getter := crawler.NewFakeHTTPGetter().
+		RespondStatus("http://foo.bar/a", 200).
+		RespondStatus("http://foo.bar/b", 500).
+		RespondAfter("http://foo.bar/c", 200, 300*time.Millisecond)
+
+	c := crawler.NewCrawler([]string{"http://foo.bar/a", "http://foo.bar/b", "http://foo.bar/c"},
+		crawler.CrawlConfig{Throttle: 3, HTTPGetter: getter})
+	results := c.Results()
+	c.Start(make(chan struct{}))
+
+	<-results
+	<-results
+	snapshot := c.Snapshot()
+	if snapshot.Total != 2 || len(snapshot.Non200Urls) != 1 || len(snapshot.Times200) != 1 {
+		t.Fatal("Expected a snapshot of the first 2 results, got", snapshot.Total, snapshot.Non200Urls)
+	}
+
+	snapshot.StatusCodes[200] = 42
+	snapshot.Non200Urls[0].URL = "changed"
+	for range results {
+	}
+
+	stats, _ := c.Wait()
+	if stats.Total != 3 || stats.StatusCodes[200] != 2 || stats.Non200Urls[0].URL == "changed" {
+		t.Fatal("Expected the snapshot not to share the statistics, got", stats.StatusCodes, stats.Non200Urls[0].URL)
+	}
+	if len(snapshot.Results) != 0 || len(stats.Results) != 3 {
+		t.Fatal("Expected the results only in the final statistics, got", len(snapshot.Results), len(stats.Results))
+	}
+	if snapshot.Total != 2 {
+		t.Fatal("Expected the snapshot not to follow the crawl, got", snapshot.Total)
+	}
+}
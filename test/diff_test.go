@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestDiffCrawlStats(t *testing.T) {
+	before := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "/same", StatusCode: 200},
+			{URL: "/broken", StatusCode: 200, Time: 10 * time.Millisecond},
+			{URL: "/fixed", StatusCode: 500},
+			{URL: "/gone", StatusCode: 404},
+			{URL: "/removed", StatusCode: 200},
+			{URL: "/retried", StatusCode: 503},
+			{URL: "/retried", StatusCode: 200},
+		},
+	}
+	after := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "/same", StatusCode: 200},
+			{URL: "/broken", StatusCode: 500, Time: 20 * time.Millisecond},
+			{URL: "/fixed", StatusCode: 200},
+			{URL: "/gone", StatusCode: 410},
+			{URL: "/added", StatusCode: 200},
+			{URL: "/retried", StatusCode: 200},
+		},
+	}
+
+	diff := crawler.DiffCrawlStats(before, after)
+	expected := crawler.StatsDiff{
+		NewlyFailing: []crawler.URLChange{{URL: "/broken", StatusCodeBefore: 200, StatusCodeAfter: 500,
+			TimeBefore: 10 * time.Millisecond, TimeAfter: 20 * time.Millisecond}},
+		NewlyPassing:  []crawler.URLChange{{URL: "/fixed", StatusCodeBefore: 500, StatusCodeAfter: 200}},
+		StatusChanged: []crawler.URLChange{{URL: "/gone", StatusCodeBefore: 404, StatusCodeAfter: 410}},
+		Added:         []string{"/added"},
+		Removed:       []string{"/removed"},
+	}
+
+	diffJSON, _ := json.Marshal(diff)
+	expectedJSON, _ := json.Marshal(expected)
+	if string(diffJSON) != string(expectedJSON) {
+		t.Fatal("Unexpected diff", string(diffJSON))
+	}
+
+	if !diff.HasChanges() || crawler.DiffCrawlStats(after, after).HasChanges() {
+		t.Fatal("Expected changes between different crawls only")
+	}
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestAsyncCrawlFakeHTTPGetter(t *testing.T) {
+	getter := crawler.NewFakeHTTPGetter().
+		RespondAfter("http://foo.bar/fast", 200, 10*time.Millisecond).
+		RespondAfter("http://foo.bar/slow", 200, 30*time.Millisecond).
+		RespondStatus("http://foo.bar/gone", 410).
+		RespondError("http://foo.bar/down", errors.New("connection refused"))
+
+	urls := []string{"http://foo.bar/fast", "http://foo.bar/slow", "http://foo.bar/gone",
+		"http://foo.bar/down", "http://foo.bar/unscripted"}
+	config := crawler.CrawlConfig{Throttle: 2, HTTPGetter: getter}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil {
+		t.Fatal("Expected the failures to be reported")
+	}
+
+	if stats.Total != 5 || stats.Successes != 2 || stats.StatusCodes[410] != 1 ||
+		stats.StatusCodes[404] != 1 || stats.StatusCodes[0] != 1 {
+		t.Fatal("Unexpected statistics", stats.Total, stats.Successes, stats.StatusCodes)
+	}
+
+	if stats.Max200Time != 30*time.Millisecond || stats.Average200Time != 20*time.Millisecond {
+		t.Fatal("Expected the scripted delays as times, got", stats.Max200Time, stats.Average200Time)
+	}
+
+	requested := getter.Requested()
+	sort.Strings(requested)
+	sort.Strings(urls)
+	if !reflect.DeepEqual(requested, urls) {
+		t.Fatal("Expected every URL to be requested once, got", requested)
+	}
+}
+
+func TestFakeHTTPGetterRequestTimeout(t *testing.T) {
+	getter := crawler.NewFakeHTTPGetter()
+	getter.Default = &crawler.FakeResponse{StatusCode: 200, Delay: time.Second}
+
+	start := time.Now()
+	response := getter.Get("http://foo.bar/", crawler.HTTPConfig{RequestTimeout: 20 * time.Millisecond})
+	if response.Err == nil || response.StatusCode != 0 || time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Expected the delay to be cut by the request timeout, got", response.StatusCode, response.Err)
+	}
+}
@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestFilterUrls(t *testing.T) {
+	urls := []string{
+		"https://foo.bar/product/1",
+		"https://foo.bar/product/2-draft",
+		"https://foo.bar/category/1",
+		"https://foo.bar/about",
+	}
+
+	config := crawler.CrawlConfig{
+		IncludePatterns: []string{"/product/", "/category/"},
+		ExcludePatterns: []string{"draft$", "/category/"},
+	}
+
+	filtered, err := crawler.FilterUrls(urls, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://foo.bar/product/1"}
+	if !testEq(filtered, expected) {
+		t.Fatal("Expected", expected, "but got", filtered)
+	}
+}
+
+func TestFilterUrlsInvalidPattern(t *testing.T) {
+	_, err := crawler.FilterUrls([]string{"https://foo.bar"}, crawler.CrawlConfig{
+		ExcludePatterns: []string{"("},
+	})
+
+	if err == nil {
+		t.Fatal("Expected an error for an invalid pattern")
+	}
+}
+
+func TestResolveUrls(t *testing.T) {
+	urls := []string{
+		"https://foo.bar/product/1",
+		"https://www.foo.bar/product/1",
+		"https://foo.bar/product/2-draft",
+		"https://foo.bar/about?lang=en",
+	}
+
+	config := crawler.CrawlConfig{
+		Host:            "staging.foo.bar:8080",
+		ExcludePatterns: []string{"draft$"},
+		DedupeUrls:      true,
+	}
+
+	resolved, err := crawler.ResolveUrls(urls, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"https://staging.foo.bar:8080/product/1",
+		"https://staging.foo.bar:8080/about?lang=en",
+	}
+	if !testEq(resolved, expected) {
+		t.Fatal("Expected", expected, "but got", resolved)
+	}
+}
+
+func TestAsyncCrawlDryRun(t *testing.T) {
+	config := crawler.CrawlConfig{
+		DryRun: true,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				t.Error("No request expected in dry run mode, got", url)
+				return nil
+			},
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{"https://foo.bar/"}, config, make(chan struct{}))
+	if err != nil || stats.Total != 0 {
+		t.Fatal("Expected nothing to be crawled, got", stats.Total, err)
+	}
+}
+
+func TestResolveUrlsMaxURLs(t *testing.T) {
+	urls := []string{
+		"https://foo.bar/draft",
+		"https://foo.bar/1",
+		"https://foo.bar/2",
+		"https://foo.bar/3",
+	}
+
+	config := crawler.CrawlConfig{
+		ExcludePatterns: []string{"draft"},
+		MaxURLs:         2,
+	}
+
+	resolved, err := crawler.ResolveUrls(urls, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://foo.bar/1", "https://foo.bar/2"}
+	if !testEq(resolved, expected) {
+		t.Fatal("Expected", expected, "but got", resolved)
+	}
+
+	config.MaxURLs = 10
+	resolved, _ = crawler.ResolveUrls(urls, config)
+	if len(resolved) != 3 {
+		t.Fatal("Expected every filtered URL to be kept, got", resolved)
+	}
+}
+
+func TestResolveUrlsSample(t *testing.T) {
+	var urls []string
+	for i := 0; i < 100; i++ {
+		urls = append(urls, fmt.Sprintf("https://foo.bar/%02d", i))
+	}
+
+	config := crawler.CrawlConfig{
+		SampleN:    10,
+		SampleSeed: 42,
+	}
+
+	sample, _ := crawler.ResolveUrls(urls, config)
+	if len(sample) != 10 || !sort.StringsAreSorted(sample) {
+		t.Fatal("Expected 10 URLs in their original order, got", sample)
+	}
+
+	sameSample, _ := crawler.ResolveUrls(urls, config)
+	if !testEq(sample, sameSample) {
+		t.Fatal("Expected the same seed to pick the same sample, got", sample, sameSample)
+	}
+
+	config.MaxURLs = 3
+	limited, _ := crawler.ResolveUrls(urls, config)
+	if !testEq(limited, sample[:3]) {
+		t.Fatal("Expected the sample to be limited, got", limited)
+	}
+}
+
+func TestResolveUrlsSchemeOverride(t *testing.T) {
+	urls := []string{"http://foo.bar/page?lang=en", "https://foo.bar/"}
+
+	resolved, err := crawler.ResolveUrls(urls, crawler.CrawlConfig{Scheme: "https"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://foo.bar/page?lang=en", "https://foo.bar/"}
+	if !testEq(resolved, expected) {
+		t.Fatal("Expected", expected, "but got", resolved)
+	}
+
+	resolved, _ = crawler.ResolveUrls(urls, crawler.CrawlConfig{Scheme: "http", Host: "staging.foo.bar"})
+	expected = []string{"http://staging.foo.bar/page?lang=en", "http://staging.foo.bar/"}
+	if !testEq(resolved, expected) {
+		t.Fatal("Expected", expected, "but got", resolved)
+	}
+
+	if _, err := crawler.ResolveUrls(urls, crawler.CrawlConfig{Scheme: "ftp"}); err == nil {
+		t.Fatal("Expected an error for an unsupported scheme")
+	}
+}
+
+func TestResolveUrlsHostOverride(t *testing.T) {
+	urls := []string{"https://foo.bar/page?lang=en#top", "http://foo.bar:8080/path/"}
+
+	expected := map[string][]string{
+		"localhost:8443": {"https://localhost:8443/page?lang=en#top", "http://localhost:8443/path/"},
+		"localhost":      {"https://localhost/page?lang=en#top", "http://localhost:8080/path/"},
+		"[::1]:8080":     {"https://[::1]:8080/page?lang=en#top", "http://[::1]:8080/path/"},
+		"[::1]":          {"https://[::1]/page?lang=en#top", "http://[::1]:8080/path/"},
+		"::1":            {"https://[::1]/page?lang=en#top", "http://[::1]:8080/path/"},
+		"127.0.0.1:80":   {"https://127.0.0.1:80/page?lang=en#top", "http://127.0.0.1:80/path/"},
+	}
+	for host, expectedUrls := range expected {
+		resolved, err := crawler.ResolveUrls(urls, crawler.CrawlConfig{Host: host})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !testEq(resolved, expectedUrls) {
+			t.Fatal("Expected", expectedUrls, "for", host, "but got", resolved)
+		}
+	}
+
+	for _, host := range []string{"foo.bar/path", "http://foo.bar", ":8080"} {
+		if _, err := crawler.ResolveUrls(urls, crawler.CrawlConfig{Host: host}); err == nil {
+			t.Fatal("Expected an error for", host)
+		}
+	}
+}
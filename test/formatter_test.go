@@ -0,0 +1,46 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+type tsvFormatter struct{}
+
+func (tsvFormatter) Format(result crawler.CrawlResult) string {
+	return fmt.Sprintf("%s\t%d", result.URL, result.StatusCode)
+}
+
+func (tsvFormatter) Summary(stats crawler.CrawlStats) string {
+	return fmt.Sprintf("total\t%d", stats.Total)
+}
+
+func TestAsyncCrawlFormatter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	config := crawler.CrawlConfig{
+		Throttle:        1,
+		HTTPGetter:      &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Formatter:       tsvFormatter{},
+		FormatterOutput: &output,
+	}
+
+	crawler.AsyncCrawl([]string{server.URL + "/", server.URL + "/missing"}, config, make(chan struct{}))
+
+	expected := server.URL + "/\t200\n" + server.URL + "/missing\t404\ntotal\t2\n"
+	if output.String() != expected {
+		t.Fatal("Invalid formatted output", strings.Split(output.String(), "\n"))
+	}
+}
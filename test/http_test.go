@@ -1,12 +1,21 @@
 package crawler
 
 import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Pixep/crowlet/pkg/crawler"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 var waitMutex = &sync.Mutex{}
@@ -49,6 +58,270 @@ func TestRunConcurrentGet(t *testing.T) {
 	}
 }
 
+func TestHTTPGetRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL, crawler.HTTPConfig{RequestTimeout: 50 * time.Millisecond})
+
+	if response.StatusCode != 0 || response.Err == nil ||
+		!strings.Contains(response.Err.Error(), "timed out") {
+		t.Fatal("Expected a timeout error, got", response.StatusCode, response.Err)
+	}
+}
+
+func TestRunConcurrentGetQuitAbortsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+	defer server.Close()
+
+	resultChan := make(chan *crawler.HTTPResponse, 1)
+	quitChan := make(chan struct{})
+	start := time.Now()
+
+	go crawler.RunConcurrentGet(crawler.HTTPGet, []string{server.URL}, crawler.HTTPConfig{}, 1, resultChan, quitChan)
+	time.Sleep(100 * time.Millisecond)
+	close(quitChan)
+
+	for range resultChan {
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatal("In-flight request was not aborted on quit")
+	}
+}
+
+func TestHTTPGetHeaders(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	crawler.HTTPGet(server.URL, crawler.HTTPConfig{
+		UserAgent: "crowlet/test",
+		User:      "user",
+		Pass:      "pass",
+		Headers: map[string]string{
+			"X-Env-Token": "secret",
+			"Host":        "staging.foo.bar",
+		},
+	})
+
+	if received.Header.Get("X-Env-Token") != "secret" || received.Host != "staging.foo.bar" {
+		t.Fatal("Custom headers were not sent", received.Header, received.Host)
+	}
+
+	if received.UserAgent() != "crowlet/test" {
+		t.Fatal("User agent was not sent, got", received.UserAgent())
+	}
+
+	if user, pass, ok := received.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Fatal("Basic auth was not sent along custom headers")
+	}
+}
+
+func TestAsyncCrawlBearerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{BearerToken: "token"},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL}, config, make(chan struct{}))
+	if err != nil || stats.StatusCodes[200] != 1 {
+		t.Fatal("Expected the bearer token to be sent, got", stats.Non200Urls, err)
+	}
+
+	config.HTTP.User = "user"
+	_, err = crawler.AsyncCrawl([]string{server.URL}, config, make(chan struct{}))
+	if !errors.Is(err, crawler.ErrConflictingAuth) {
+		t.Fatal("Expected combining basic auth and a bearer token to fail, got", err)
+	}
+}
+
+func TestHTTPGetHostAuth(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	config := crawler.HTTPConfig{
+		User: "global",
+		Pass: "pass",
+		HostAuth: map[string]crawler.HostAuth{
+			host:          {BearerToken: "token", Headers: map[string]string{"X-Env-Token": "secret"}},
+			"other.local": {User: "other", Pass: "pass"},
+		},
+	}
+
+	crawler.HTTPGet(server.URL, config)
+	if received.Header.Get("Authorization") != "Bearer token" || received.Header.Get("X-Env-Token") != "secret" {
+		t.Fatal("Expected the credentials of the host to be sent, got", received.Header)
+	}
+
+	delete(config.HostAuth, host)
+	crawler.HTTPGet(server.URL, config)
+	if user, _, ok := received.BasicAuth(); !ok || user != "global" || received.Header.Get("X-Env-Token") != "" {
+		t.Fatal("Expected the global credentials for hosts not listed, got", received.Header)
+	}
+}
+
+func TestHTTPGetHeadMethod(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.URL.Path == "/no-head" && r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.HTTPConfig{Method: http.MethodHead}
+	response := crawler.HTTPGet(server.URL+"/head", config)
+	if response.StatusCode != 200 || !testEq(methods, []string{"HEAD"}) {
+		t.Fatal("Expected a single HEAD request, got", methods, response.StatusCode)
+	}
+
+	methods = nil
+	response = crawler.HTTPGet(server.URL+"/no-head", config)
+	if response.StatusCode != 200 || !testEq(methods, []string{"HEAD", "GET"}) {
+		t.Fatal("Expected a fallback to GET, got", methods, response.StatusCode)
+	}
+
+	methods = nil
+	config.ParseLinks = true
+	crawler.HTTPGet(server.URL+"/head", config)
+	if !testEq(methods, []string{"GET"}) {
+		t.Fatal("Expected GET when parsing links, got", methods)
+	}
+}
+
+func TestHTTPGetMaxBodyBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 10000))
+	}))
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL, crawler.HTTPConfig{})
+	if response.StatusCode != 200 || response.BodySize != 10000 {
+		t.Fatal("Expected the full body to be read, got", response.BodySize)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{MaxBodyBytes: 100})
+	if response.StatusCode != 200 || response.BodySize != 100 {
+		t.Fatal("Expected the body to be truncated, got", response.BodySize)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{DiscardBody: true})
+	if response.StatusCode != 200 || response.BodySize != 0 {
+		t.Fatal("Expected the body to be discarded, got", response.BodySize)
+	}
+}
+
+func TestAsyncCrawlTotalBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1000))
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{MaxBodyBytes: 600},
+	}
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.TotalBytes != 1800 {
+		t.Fatal("Expected the truncated body sizes to be summed, got", stats.TotalBytes, err)
+	}
+
+	if merged := crawler.MergeCrawlStats(stats, stats); merged.TotalBytes != 3600 {
+		t.Fatal("Expected total bytes to be merged, got", merged.TotalBytes)
+	}
+}
+
+func TestHTTPGetProtocol(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL, crawler.HTTPConfig{DisableHTTP2: true})
+	if response.Err != nil || response.Proto != "HTTP/1.1" {
+		t.Fatal("Expected an HTTP/1.1 response, got", response.Proto, response.Err)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{ForceHTTP2: true})
+	if !errors.Is(response.Err, crawler.ErrHTTP2NotNegotiated) || response.StatusCode != 0 {
+		t.Fatal("Expected HTTP/1.1 responses to fail when forcing HTTP/2, got", response.Err)
+	}
+}
+
+func TestAsyncCrawlDisableKeepAlives(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{MaxIdleConnsPerHost: 1},
+	}
+
+	crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if count := atomic.LoadInt32(&connections); count != 1 {
+		t.Fatal("Expected connections to be reused, got", count)
+	}
+
+	atomic.StoreInt32(&connections, 0)
+	config.HTTP.DisableKeepAlives = true
+	crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if count := atomic.LoadInt32(&connections); count != 3 {
+		t.Fatal("Expected a connection per request, got", count)
+	}
+}
+
+func TestHTTPGetProxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	response := crawler.HTTPGet("http://internal.foo.bar/page", crawler.HTTPConfig{Proxy: proxy.URL})
+	if response.StatusCode != 200 || proxiedURL != "http://internal.foo.bar/page" {
+		t.Fatal("Expected the request to go through the proxy, got", proxiedURL, response.Err)
+	}
+
+	config := crawler.CrawlConfig{
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{Proxy: "ftp://proxy.foo.bar"},
+	}
+	_, err := crawler.AsyncCrawl([]string{"http://internal.foo.bar/page"}, config, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "unsupported proxy scheme") {
+		t.Fatal("Expected an unsupported proxy error, got", err)
+	}
+}
+
 func mockHTTPGet(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
 	fetchedUrls = append(fetchedUrls, url)
 	waitMutex.Lock()
@@ -76,3 +349,166 @@ func testEq(a, b []string) bool {
 
 	return true
 }
+
+func TestHTTPGetCaptureHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Add("X-Cache", "HIT")
+		w.Header().Add("X-Cache", "MISS")
+	}))
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL, crawler.HTTPConfig{CaptureHeaders: []string{"cache-control", "X-Cache", "ETag"}})
+
+	expected := map[string]string{"cache-control": "max-age=60", "X-Cache": "HIT, MISS"}
+	if !reflect.DeepEqual(response.Headers, expected) {
+		t.Fatal("Expected", expected, "but got", response.Headers)
+	}
+}
+
+func TestHTTPGetLogFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	hook := logtest.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+	getter := &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet}
+	for range getter.ConcurrentHTTPGet([]string{server.URL}, crawler.HTTPConfig{}, 1, make(chan struct{})) {
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Data["url"] != server.URL || entry.Data["status"] != http.StatusNotFound ||
+		entry.Data["attempt"] != 1 {
+		t.Fatal("Invalid result log entry", entry)
+	}
+
+	if _, found := entry.Data["duration_ms"]; !found {
+		t.Fatal("Expected a duration_ms field, got", entry.Data)
+	}
+}
+
+func TestAsyncCrawlSoft404(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Write([]byte("<html><h1>Page Not Found</h1></html>"))
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("page not found"))
+		default:
+			w.Write([]byte("<html><h1>Welcome</h1></html>"))
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			Soft404Patterns: []string{"page not found"},
+			DiscardBody:     true,
+		},
+	}
+
+	urls := []string{server.URL + "/", server.URL + "/missing", server.URL + "/gone"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil || len(stats.Soft404Urls) != 1 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected 1 soft 404 and 1 non-200 URL, got", stats.Soft404Urls, stats.Non200Urls, err)
+	}
+
+	if soft404 := stats.Soft404Urls[0]; soft404.URL != server.URL+"/missing" || soft404.Soft404Match != "page not found" {
+		t.Fatal("Invalid soft 404 result", soft404)
+	}
+}
+
+func TestAsyncCrawlCacheStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hit":
+			w.Header().Set("X-Cache", "Hit from cloudfront")
+		case "/miss":
+			w.Header().Set("X-Cache", "MISS")
+		case "/bypass":
+			w.Header().Set("X-Cache", "BYPASS")
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{CacheStatusHeader: "x-cache"},
+	}
+
+	urls := []string{server.URL + "/hit", server.URL + "/miss", server.URL + "/bypass", server.URL + "/uncached"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Cache.Hits != 1 || stats.Cache.Misses != 1 || stats.Cache.Other != 1 {
+		t.Fatal("Expected 1 hit, 1 miss and 1 other cache status, got", stats.Cache, err)
+	}
+
+	if stats.Cache.Statuses["BYPASS"] != 1 || len(stats.Cache.Statuses) != 3 || stats.Cache.HitRatio() != 1.0/3 {
+		t.Fatal("Invalid cache statuses", stats.Cache)
+	}
+
+	merged := crawler.MergeCrawlStats(stats, stats)
+	if merged.Cache.Hits != 2 || merged.Cache.Statuses["MISS"] != 2 {
+		t.Fatal("Expected merged cache statistics to be summed, got", merged.Cache)
+	}
+}
+
+func TestAsyncCrawlErrorKinds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/truncated":
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("truncated"))
+		}
+	}))
+	defer server.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   3,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{RequestTimeout: 200 * time.Millisecond},
+	}
+
+	expected := map[string]crawler.ErrorKind{
+		server.URL + "/page":          crawler.ErrorKindNone,
+		server.URL + "/error":         crawler.ErrorKindHTTPStatus,
+		server.URL + "/slow":          crawler.ErrorKindTimeout,
+		server.URL + "/truncated":     crawler.ErrorKindRead,
+		tlsServer.URL + "/page":       crawler.ErrorKindTLS,
+		closed.URL + "/page":          crawler.ErrorKindConnect,
+		"http://crowlet.invalid/page": crawler.ErrorKindDNS,
+	}
+	var urls []string
+	for url := range expected {
+		urls = append(urls, url)
+	}
+
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+
+	for _, result := range stats.Results {
+		if result.ErrorKind != expected[result.URL] {
+			t.Error("Expected", result.URL, "to fail with kind", expected[result.URL], "but got", result.ErrorKind, result.Error)
+		}
+	}
+
+	if len(stats.Results) != len(expected) || stats.ErrorKinds[crawler.ErrorKindTimeout] != 1 ||
+		stats.ErrorKinds[crawler.ErrorKindNone] != 0 {
+		t.Fatal("Expected the results to be counted by error kind, got", stats.ErrorKinds)
+	}
+}
@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func entryPaths(entries []crawler.SitemapEntry, serverURL string) (paths []string) {
+	for _, entry := range entries {
+		paths = append(paths, strings.TrimPrefix(entry.Loc, serverURL))
+	}
+	return
+}
+
+func TestChangedSince(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	entries, err := crawler.GetSitemapEntriesRecursive(server.URL+"/metadata.xml", 1)
+	if err != nil || len(entries) != 5 || entries[0].LastMod != "2020-01-02T10:00:00+01:00" {
+		t.Fatal("Expected the sitemap entries with their lastmod, got", entries, err)
+	}
+
+	since, err := crawler.ParseLastMod("2021-01-01")
+	if err != nil || !since.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Failed to parse the date", since, err)
+	}
+
+	changed := entryPaths(crawler.ChangedSince(entries, since), server.URL)
+	expected := []string{"/future", "/invalid-date", "/invalid-changefreq", "/invalid-priority"}
+	if !testEq(changed, expected) {
+		t.Fatal("Expected", expected, "but got", changed)
+	}
+
+	if _, err := crawler.ParseLastMod("01/02/2020"); err == nil {
+		t.Fatal("Expected an invalid date error")
+	}
+}
+
+func TestLastModState(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crowlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "lastmod.json")
+
+	entries, err := crawler.GetSitemapEntriesRecursive(server.URL+"/metadata.xml", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := crawler.LoadLastModState(statePath)
+	if err != nil || len(state.Changed(entries)) != len(entries) {
+		t.Fatal("Expected every entry to be changed without state", err)
+	}
+
+	// The URLs were crawled on another host, and the future one failed
+	stats := crawler.CrawlStats{Results: []crawler.CrawlResult{
+		{URL: "https://staging.foo.bar/valid", StatusCode: 200},
+		{URL: "https://staging.foo.bar/future", StatusCode: 404},
+	}}
+	state.Record(entries, stats)
+	if err := state.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = crawler.LoadLastModState(statePath)
+	if err != nil || len(state) != 1 {
+		t.Fatal("Expected the successful entry only to be recorded, got", state, err)
+	}
+
+	changed := entryPaths(state.Changed(entries), server.URL)
+	expected := []string{"/future", "/invalid-date", "/invalid-changefreq", "/invalid-priority"}
+	if !testEq(changed, expected) {
+		t.Fatal("Expected", expected, "but got", changed)
+	}
+}
+
+func TestAsyncCrawlIfModifiedSince(t *testing.T) {
+	lastModified := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer server.Close()
+
+	entries := []crawler.SitemapEntry{
+		{Loc: "http://foo.bar/unchanged", LastMod: "2022-03-01"},
+		{Loc: "http://foo.bar/changed", LastMod: "2022-02-01"},
+		{Loc: "http://foo.bar/unknown"},
+	}
+	modifiedSince := crawler.ModifiedSince(entries)
+	if len(modifiedSince) != 2 || !modifiedSince["http://foo.bar/unchanged"].Equal(lastModified) {
+		t.Fatal("Expected the valid lastmod dates by URL, got", modifiedSince)
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		HTTP:       crawler.HTTPConfig{IfModifiedSince: modifiedSince},
+	}
+
+	stats, err := crawler.AsyncCrawl(crawler.EntryUrls(entries), config, make(chan struct{}))
+	if err != nil || stats.NotModified != 1 || stats.StatusCodes[200] != 2 || len(stats.Redirects) != 0 {
+		t.Fatal("Expected the unchanged URL to answer 304 as a healthy response, got", stats.StatusCodes, err)
+	}
+
+	state := crawler.LastModState{}
+	state.Record(entries, stats)
+	if state["http://foo.bar/unchanged"] != "2022-03-01" {
+		t.Fatal("Expected the 304 entry to be recorded, got", state)
+	}
+}
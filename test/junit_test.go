@@ -0,0 +1,104 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	stats := crawler.CrawlStats{
+		WallClock: 1500 * time.Millisecond,
+		Results: []crawler.CrawlResult{
+			{URL: "https://foo.bar/ok", StatusCode: 200, Time: 100 * time.Millisecond},
+			{URL: "https://foo.bar/gone", StatusCode: 404, LinkingURLs: []string{"https://foo.bar/ok"}},
+		},
+	}
+
+	var report strings.Builder
+	err := crawler.WriteJUnitReport(&report, stats, crawler.CrawlConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var suite struct {
+		Tests     int     `xml:"tests,attr"`
+		Failures  int     `xml:"failures,attr"`
+		Time      float64 `xml:"time,attr"`
+		TestCases []struct {
+			Name    string `xml:"name,attr"`
+			Failure *struct {
+				Message string `xml:"message,attr"`
+				Body    string `xml:",chardata"`
+			} `xml:"failure"`
+		} `xml:"testcase"`
+	}
+	err = xml.Unmarshal([]byte(report.String()), &suite)
+	if err != nil {
+		t.Fatal("Invalid JUnit report:", err)
+	}
+
+	if suite.Tests != 2 || suite.Failures != 1 || suite.Time != 1.5 || len(suite.TestCases) != 2 {
+		t.Fatal("Invalid test suite:\n" + report.String())
+	}
+
+	if suite.TestCases[0].Failure != nil {
+		t.Fatal("Expected 200 URL to pass")
+	}
+
+	failure := suite.TestCases[1].Failure
+	if failure == nil || failure.Message != "status code 404" ||
+		!strings.Contains(failure.Body, "https://foo.bar/ok") {
+		t.Fatal("Invalid failure:\n" + report.String())
+	}
+}
+
+func TestWriteJUnitReportFailOn(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "https://foo.bar/gone", StatusCode: 404},
+			{URL: "https://foo.bar/down", StatusCode: 503},
+		},
+	}
+
+	failOn, err := crawler.ParseStatusPolicy("5xx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report strings.Builder
+	err = crawler.WriteJUnitReport(&report, stats, crawler.CrawlConfig{FailOn: failOn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(report.String(), `failures="1"`) ||
+		!strings.Contains(report.String(), `message="status code 503"`) {
+		t.Fatal("Expected only the 503 URL to fail:\n" + report.String())
+	}
+}
+
+func TestWriteJUnitReportSuccessCodes(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "https://foo.bar/created", StatusCode: 201},
+			{URL: "https://foo.bar/deleted", StatusCode: 204},
+			{URL: "https://foo.bar/ok", StatusCode: 200},
+		},
+	}
+
+	var report strings.Builder
+	config := crawler.CrawlConfig{SuccessCodes: map[int]bool{201: true, 204: true}}
+	err := crawler.WriteJUnitReport(&report, stats, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(report.String(), `failures="1"`) ||
+		!strings.Contains(report.String(), `message="status code 200"`) {
+		t.Fatal("Expected only the URL answering outside the success codes to fail:\n" + report.String())
+	}
+}
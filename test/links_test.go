@@ -0,0 +1,530 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func newLinkedPagesServer() *httptest.Server {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a>`,
+		"/a": `<a href="/b">b</a><a href="/">home</a>`,
+		"/b": `<a href="/missing">missing</a><a href="/a">a</a>`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><body>"+page+"</body></html>")
+	}))
+}
+
+func TestAsyncCrawlLinkDepth(t *testing.T) {
+	server := newLinkedPagesServer()
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+			MaxLinkDepth:    2,
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err != nil || stats.Total != 3 {
+		t.Fatal("Expected 3 URLs crawled without error, got", stats.Total, err)
+	}
+
+	config.Links.MaxLinkDepth = 3
+	stats, _ = crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if stats.Total != 4 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected 4 URLs crawled with 1 error, got", stats.Total, stats.Non200Urls)
+	}
+
+	broken := stats.Non200Urls[0]
+	if broken.URL != server.URL+"/missing" || broken.Depth != 3 ||
+		len(broken.LinkingURLs) != 1 || broken.LinkingURLs[0] != server.URL+"/b" {
+		t.Fatal("Invalid broken link result", broken)
+	}
+}
+
+func TestWriteBrokenLinksCSV(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Non200Urls: []crawler.CrawlResult{
+			{URL: "https://foo.bar/gone", StatusCode: 410, LinkingURLs: []string{"https://foo.bar/b", "https://foo.bar/a"}},
+			{URL: "https://foo.bar/error", StatusCode: 500, LinkingURLs: []string{"https://foo.bar/b"}},
+			{URL: "https://foo.bar/sitemap-page", StatusCode: 404},
+		},
+	}
+
+	var report strings.Builder
+	err := crawler.WriteBrokenLinksCSV(&report, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "source,target,status\n" +
+		"https://foo.bar/a,https://foo.bar/gone,410\n" +
+		"https://foo.bar/b,https://foo.bar/error,500\n" +
+		"https://foo.bar/b,https://foo.bar/gone,410\n"
+	if report.String() != expected {
+		t.Fatal("Unexpected report:\n" + report.String())
+	}
+}
+
+func TestAsyncCrawlContentTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><img src="/logo.png"><img src="/broken.png"></body></html>`)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+		case "/broken.png":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlImages:          true,
+			ExpectedContentTypes: crawler.DefaultExpectedContentTypes,
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err == nil || stats.Total != 3 || len(stats.ContentTypeMismatches) != 1 {
+		t.Fatal("Expected a single content type mismatch, got", stats.ContentTypeMismatches, err)
+	}
+
+	mismatch := stats.ContentTypeMismatches[0]
+	if mismatch.URL != server.URL+"/broken.png" || mismatch.ExpectedContentType != "image/" {
+		t.Fatal("Invalid content type mismatch", mismatch)
+	}
+}
+
+func TestCSVResultWriter(t *testing.T) {
+	var output strings.Builder
+	writer, err := crawler.NewCSVResultWriter(&output)
+	if err != nil || output.String() != "url,status,server-time-ms\n" {
+		t.Fatal("Expected the header to be written first, got", output.String(), err)
+	}
+
+	writer.OnResult(crawler.CrawlResult{URL: "https://foo.bar/a", StatusCode: 200, Time: 1500 * time.Microsecond},
+		crawler.Progress{Completed: 1, Total: 2})
+	if output.String() != "url,status,server-time-ms\nhttps://foo.bar/a,200,1\n" {
+		t.Fatal("Expected the row to be flushed, got", output.String())
+	}
+
+	writer.OnResult(crawler.CrawlResult{URL: "https://foo.bar/b", StatusCode: 404}, crawler.Progress{Completed: 2, Total: 2})
+	if !strings.HasSuffix(output.String(), "https://foo.bar/b,404,0\n") || writer.Error() != nil {
+		t.Fatal("Unexpected output:\n" + output.String())
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	var output strings.Builder
+	writer := crawler.NewNDJSONWriter(&output)
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		OnResult:   writer.OnResult,
+	}
+
+	_, err := crawler.AsyncCrawl([]string{server.URL + "/page", server.URL + "/old"}, config, make(chan struct{}))
+	if err != nil || writer.Error() != nil {
+		t.Fatal("Unexpected error", err, writer.Error())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatal("Expected a line per result, got:\n" + output.String())
+	}
+
+	finalURLs := make(map[string]string)
+	for _, line := range lines {
+		var result crawler.CrawlResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil || result.StatusCode != 200 {
+			t.Fatal("Invalid result line", line, err)
+		}
+		finalURLs[result.URL] = result.FinalURL
+	}
+
+	if finalURLs[server.URL+"/old"] != server.URL+"/new" {
+		t.Fatal("Expected the final URL of the redirected URL, got", finalURLs)
+	}
+}
+
+func TestAsyncCrawlAlternateLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/", "/fr/":
+			fmt.Fprint(w, `<html><head>`+
+				`<link rel="canonical" href="/">`+
+				`<link rel="alternate" hreflang="fr" href="/fr/">`+
+				`<link rel="alternate" hreflang="de" href="/de/">`+
+				`<link rel="stylesheet" href="/style.css">`+
+				`</head><body></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+		},
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if stats.Total != 1 {
+		t.Fatal("Expected alternate links to be ignored by default, got", stats.Total)
+	}
+
+	config.Links.CrawlAlternateLinks = true
+	stats, _ = crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if stats.Total != 3 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected 3 URLs crawled with 1 error, got", stats.Total, stats.Non200Urls)
+	}
+
+	broken := stats.Non200Urls[0]
+	if broken.URL != server.URL+"/de/" || len(broken.LinkingURLs) != 1 || broken.LinkingURLs[0] != server.URL+"/" {
+		t.Fatal("Invalid broken alternate link result", broken)
+	}
+}
+
+func TestAsyncCrawlMixedContent(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>`+
+			`<img src="http://insecure.example.com/image.png">`+
+			`<img src="/secure.png">`+
+			`<a href="http://insecure.example.com/">link</a>`+
+			`</body></html>`)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{InsecureSkipVerify: true},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err != nil || len(stats.MixedContent) != 0 {
+		t.Fatal("Expected mixed content to be ignored by default, got", stats.MixedContent, err)
+	}
+
+	config.DetectMixedContent = true
+	stats, err = crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err == nil || stats.Total != 1 || len(stats.MixedContent) != 1 {
+		t.Fatal("Expected 1 mixed content violation, got", stats.Total, stats.MixedContent, err)
+	}
+
+	violation := stats.MixedContent[0]
+	if violation.SourceURL != server.URL+"/" || violation.TargetURL != "http://insecure.example.com/image.png" {
+		t.Fatal("Invalid mixed content violation", violation)
+	}
+}
+
+func TestAsyncCrawlAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head>`+
+				`<link rel="stylesheet" href="/missing.css">`+
+				`<script src="/app.js"></script>`+
+				`<script>inline()</script>`+
+				`</head><body></body></html>`)
+		case "/app.js":
+			fmt.Fprint(w, "app()")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlScripts: true,
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err != nil || stats.Total != 2 {
+		t.Fatal("Expected the script only to be crawled, got", stats.Total, err)
+	}
+
+	config.Links.CrawlStylesheets = true
+	stats, _ = crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if stats.Total != 3 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected 3 URLs crawled with 1 error, got", stats.Total, stats.Non200Urls)
+	}
+
+	broken := stats.Non200Urls[0]
+	if broken.URL != server.URL+"/missing.css" || len(broken.LinkingURLs) != 1 || broken.LinkingURLs[0] != server.URL+"/" {
+		t.Fatal("Invalid broken stylesheet result", broken)
+	}
+}
+
+func TestAsyncCrawlNormalizeURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/missing#one">one</a><a href="/missing?b=2&a=1">query</a><a href="/other">other</a>`)
+		case "/other":
+			fmt.Fprint(w, `<a href="/missing#two">two</a><a href="/missing?a=1&b=2#three">three</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+		},
+	}
+
+	urls := []string{server.URL + "/", server.URL + "/other"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if stats.Total != 6 {
+		t.Fatal("Expected every variant to be crawled by default, got", stats.Total)
+	}
+
+	config.Links.NormalizeURLs = true
+	stats, _ = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if stats.Total != 4 || len(stats.Non200Urls) != 2 {
+		t.Fatal("Expected 4 URLs crawled with 2 errors, got", stats.Total, stats.Non200Urls)
+	}
+
+	for _, broken := range stats.Non200Urls {
+		if len(broken.LinkingURLs) != 2 {
+			t.Fatal("Expected linking URLs of every variant to be aggregated, got", broken.URL, broken.LinkingURLs)
+		}
+	}
+}
+
+func TestAsyncCrawlLinkPrefixAllow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/":
+			fmt.Fprint(w, `<a href="/docs/guide">guide</a><a href="/blog/post">post</a><a href="/">home</a>`)
+		case "/docs/guide":
+			fmt.Fprint(w, `<a href="/docs/api">api</a><a href="/pricing">pricing</a>`)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+			MaxLinkDepth:    3,
+			LinkPrefixAllow: []string{"/docs/"},
+		},
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/docs/"}, config, make(chan struct{}))
+
+	var crawled []string
+	for _, result := range stats.Results {
+		crawled = append(crawled, strings.TrimPrefix(result.URL, server.URL))
+	}
+	sort.Strings(crawled)
+	if !testEq(crawled, []string{"/docs/", "/docs/api", "/docs/guide"}) {
+		t.Fatal("Expected only linked URLs within the prefix to be crawled, got", crawled)
+	}
+}
+
+func TestWriteHeadersCSV(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Results: []crawler.CrawlResult{
+			{URL: "https://foo.bar/b", StatusCode: 404},
+			{URL: "https://foo.bar/a", StatusCode: 200, Headers: map[string]string{"ETag": `"abc"`, "Cache-Control": "no-cache"}},
+		},
+	}
+
+	var output strings.Builder
+	if err := crawler.WriteHeadersCSV(&output, stats, []string{"Cache-Control", "ETag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "url,status,Cache-Control,ETag\n" +
+		"https://foo.bar/a,200,no-cache,\"\"\"abc\"\"\"\n" +
+		"https://foo.bar/b,404,,\n"
+	if output.String() != expected {
+		t.Fatal("Invalid headers CSV", output.String())
+	}
+}
+
+func TestAsyncCrawlCheckFragments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body>`+
+				`<a href="/guide#installation">install</a><a href="/guide#missing">missing</a>`+
+				`<a href="/old#usage">usage</a><a href="#top">top</a>`+
+				`<a href="#local">local</a><a href="#nowhere">nowhere</a>`+
+				`<div id="local"></div></body></html>`)
+		case "/guide":
+			fmt.Fprint(w, `<html><body><h2 id="installation">Installation</h2></body></html>`)
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			fmt.Fprint(w, `<html><body><a name="usage"></a></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+			CheckFragments:  true,
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	expected := []crawler.BrokenFragment{
+		{SourceURL: server.URL + "/", TargetURL: server.URL + "/", Fragment: "nowhere"},
+		{SourceURL: server.URL + "/", TargetURL: server.URL + "/guide", Fragment: "missing"},
+	}
+	if err == nil || !reflect.DeepEqual(stats.BrokenFragments, expected) {
+		t.Fatal("Expected", expected, "but got", stats.BrokenFragments, err)
+	}
+
+	config.Links.CheckFragments = false
+	stats, err = crawler.AsyncCrawl([]string{server.URL + "/"}, config, make(chan struct{}))
+	if err != nil || len(stats.BrokenFragments) != 0 {
+		t.Fatal("Expected fragments not to be checked, got", stats.BrokenFragments, err)
+	}
+}
+
+func TestAsyncCrawlLinkExtractors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"/ignored-key": "text", "items": [{"url": "/product/1"}, {"url": "https://other.test/x"}], "next": "/api?page=2", "count": 3}`)
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(w, `<rss><channel><item><link>/post</link></item></channel></rss>`)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			LinkExtractors: map[string]crawler.LinkExtractor{
+				"application/json": crawler.ExtractJSONLinks,
+				"application/rss+xml": func(body io.Reader, currentURL url.URL) ([]crawler.Link, error) {
+					var feed struct {
+						Links []string `xml:"channel>item>link"`
+					}
+					if err := xml.NewDecoder(body).Decode(&feed); err != nil {
+						return nil, err
+					}
+
+					var links []crawler.Link
+					for _, link := range feed.Links {
+						target, _ := url.Parse(link)
+						links = append(links, crawler.Link{TargetURL: *target})
+					}
+					return links, nil
+				},
+			},
+		},
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+		},
+		PreserveOrder: true,
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/api", server.URL + "/feed"}, config, make(chan struct{}))
+
+	var crawled []string
+	for _, result := range stats.Results {
+		crawled = append(crawled, strings.TrimPrefix(result.URL, server.URL))
+	}
+	expected := []string{"/api", "/feed", "/product/1", "/api?page=2", "/post"}
+	if !testEq(crawled, expected) {
+		t.Fatal("Expected the links of JSON and RSS responses to be crawled, got", crawled)
+	}
+}
+
+func TestAsyncCrawlParseErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Write([]byte(`<html><body><a href="/valid">valid</a><a href="http://[::1">broken</a><img src="/100%zz.png"></body></html>`))
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"next": "/valid", `))
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links:      crawler.CrawlLinksConfig{CrawlHyperlinks: true, CrawlImages: true},
+		HTTP: crawler.HTTPConfig{
+			LinkExtractors: map[string]crawler.LinkExtractor{"application/json": crawler.ExtractJSONLinks},
+		},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/page", server.URL + "/api"}, config, make(chan struct{}))
+	if err != nil || stats.Total != 3 {
+		t.Fatal("Expected parse errors not to be failures, and the valid link to be crawled, got", stats.Total, err)
+	}
+
+	var urls []string
+	for _, parseErr := range stats.ParseErrors {
+		urls = append(urls, parseErr.URL)
+		if parseErr.Error == "" {
+			t.Fatal("Expected the parse error message, got", parseErr)
+		}
+	}
+
+	expected := []string{server.URL + "/api", server.URL + "/page", server.URL + "/page"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Fatal("Expected the parse errors of each page sorted by URL", expected, "but got", stats.ParseErrors)
+	}
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields crawler.Fields
+}
+
+type recordingLogger struct {
+	mutex   sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level string, msg string, fields crawler.Fields) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *recordingLogger) Debug(msg string, fields crawler.Fields) { l.record("debug", msg, fields) }
+func (l *recordingLogger) Info(msg string, fields crawler.Fields)  { l.record("info", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields crawler.Fields)  { l.record("warn", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields crawler.Fields) { l.record("error", msg, fields) }
+
+func TestAsyncCrawlLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	hook := logtest.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+	logger := &recordingLogger{}
+	config := crawler.CrawlConfig{
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Logger:     logger,
+	}
+
+	crawler.AsyncCrawl([]string{server.URL + "/", "http://%zz"}, config, make(chan struct{}))
+
+	var crawled, failed bool
+	for _, entry := range logger.entries {
+		if entry.level == "info" && entry.msg == "crawled" && entry.fields["url"] == server.URL+"/" {
+			crawled = true
+		}
+		if entry.level == "error" && entry.fields["url"] == "http://%zz" {
+			failed = true
+		}
+	}
+	if !crawled || !failed {
+		t.Fatal("Expected the result and error logs to be sent to the logger, got", logger.entries)
+	}
+
+	if len(hook.AllEntries()) != 0 {
+		t.Fatal("Expected nothing to be logged to logrus, got", hook.AllEntries())
+	}
+}
+
+func TestAsyncCrawlLogLevel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	config := crawler.CrawlConfig{
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Throttle:   1,
+		Logger:     logger,
+		LogLevel:   crawler.LogWarn,
+	}
+
+	crawler.AsyncCrawl([]string{server.URL + "/", server.URL + "/missing"}, config, make(chan struct{}))
+
+	if len(logger.entries) != 1 || logger.entries[0].level != "warn" ||
+		logger.entries[0].fields["url"] != server.URL+"/missing" {
+		t.Fatal("Expected only the failing URL to be logged, got", logger.entries)
+	}
+
+	logger.entries = nil
+	config.LogLevel = crawler.LogSilent
+	crawler.AsyncCrawl([]string{server.URL + "/missing"}, config, make(chan struct{}))
+	if len(logger.entries) != 0 {
+		t.Fatal("Expected no log, got", logger.entries)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := crawler.ParseLogLevel("Warn")
+	if err != nil || level != crawler.LogWarn {
+		t.Fatal("Expected the warn level, got", level, err)
+	}
+
+	if _, err := crawler.ParseLogLevel("loud"); err == nil {
+		t.Fatal("Expected an invalid log level error")
+	}
+}
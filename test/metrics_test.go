@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAsyncCrawlWithMetrics(t *testing.T) {
+	statusHTTPGet := func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+		statusCode := 200
+		if url == "missing" {
+			statusCode = 404
+		}
+		return &crawler.HTTPResponse{URL: url, StatusCode: statusCode}
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: statusHTTPGet},
+	}
+	registry := prometheus.NewRegistry()
+	urls := []string{"page1", "page2", "missing"}
+
+	for i := 0; i < 2; i++ {
+		_, err := crawler.AsyncCrawlWithMetrics(urls, config, make(chan struct{}), registry)
+		if err == nil {
+			t.Fatal("Expected an error for the 404 URL")
+		}
+	}
+
+	metrics, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range metrics {
+		if family.GetName() == "crowlet_requests_total" &&
+			family.GetMetric()[0].GetCounter().GetValue() != 6 {
+			t.Fatal("Invalid requests total", family.GetMetric()[0].GetCounter().GetValue())
+		}
+	}
+
+	expected := 2
+	if count := testutil.CollectAndCount(registry, "crowlet_responses_total"); count != expected {
+		t.Fatal("Expected", expected, "status code series, got", count)
+	}
+}
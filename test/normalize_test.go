@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestStripUTMParams(t *testing.T) {
+	original, _ := url.Parse("https://foo.bar/page?b=2&utm_source=mail&a=1&UTM_Medium=x#top")
+	stripped := crawler.StripUTMParams(original)
+	if stripped.String() != "https://foo.bar/page?b=2&a=1#top" {
+		t.Fatal("Expected utm parameters to be stripped, got", stripped)
+	}
+
+	if original.RawQuery != "b=2&utm_source=mail&a=1&UTM_Medium=x" {
+		t.Fatal("Expected the original URL to be left untouched, got", original)
+	}
+
+	normalize, err := crawler.ParseNormalizers([]string{"strip-utm", "strip-fragment"})
+	if err != nil || normalize(original).String() != "https://foo.bar/page?b=2&a=1" {
+		t.Fatal("Expected the normalizers to be chained, got", err)
+	}
+
+	if _, err := crawler.ParseNormalizers([]string{"lowercase"}); err == nil {
+		t.Fatal("Expected an error for an unknown normalizer")
+	}
+}
+
+func TestAsyncCrawlNormalize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/page/">page</a><a href="/Page?utm_source=home">tracked</a><a href="/">home</a>`)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		DedupeUrls: true,
+		Links: crawler.CrawlLinksConfig{
+			CrawlHyperlinks: true,
+		},
+		Normalize: crawler.ChainNormalizers(crawler.StripUTMParams, func(u *url.URL) *url.URL {
+			normalized := *u
+			normalized.Path = strings.ToLower(strings.TrimSuffix(u.Path, "/"))
+			if normalized.Path == "" {
+				normalized.Path = "/"
+			}
+			return &normalized
+		}),
+	}
+
+	urls := []string{server.URL + "/", server.URL + "/?utm_campaign=sitemap"}
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+
+	var crawled []string
+	for _, result := range stats.Results {
+		crawled = append(crawled, strings.TrimPrefix(result.URL, server.URL))
+	}
+	sort.Strings(crawled)
+	if !testEq(crawled, []string{"/", "/page"}) {
+		t.Fatal("Expected equivalent URLs to be crawled once, got", crawled)
+	}
+}
@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestParseStatusPolicy(t *testing.T) {
+	policy, err := crawler.ParseStatusPolicy("404, 5xx,0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int]bool{0: true, 200: false, 301: false, 403: false, 404: true, 500: true, 503: true}
+	for statusCode, failure := range expected {
+		if policy(statusCode) != failure {
+			t.Fatal("Invalid policy result for", statusCode)
+		}
+	}
+
+	for _, spec := range []string{"abc", "6xx", "4x"} {
+		if _, err := crawler.ParseStatusPolicy(spec); err == nil {
+			t.Fatal("Expected an error for", spec)
+		}
+	}
+}
+
+func TestCrawlStatsFailures(t *testing.T) {
+	stats := crawler.CrawlStats{
+		StatusCodes: map[int]int{200: 5, 301: 2, 404: 3},
+	}
+
+	if failures := stats.Failures(nil); failures != 3 {
+		t.Fatal("Expected redirects not to be failures with the default policy, got", failures)
+	}
+
+	policy, _ := crawler.ParseStatusPolicy("4xx")
+	if failures := stats.Failures(policy); failures != 3 {
+		t.Fatal("Expected 3 failures with a 4xx policy, got", failures)
+	}
+}
+
+func TestCrawlStatsErrorClasses(t *testing.T) {
+	stats := crawler.CrawlStats{
+		StatusCodes: map[int]int{200: 3, 404: 2, 410: 1, 500: 1, 0: 1},
+		Non200Urls: []crawler.CrawlResult{
+			{URL: "/a", StatusCode: 404},
+			{URL: "/b", StatusCode: 500},
+			{URL: "/c", StatusCode: 404},
+			{URL: "/d", StatusCode: 0},
+			{URL: "/e", StatusCode: 410},
+		},
+	}
+
+	if stats.ClientErrorCount() != 3 || stats.ServerErrorCount() != 1 {
+		t.Fatal("Invalid error counts", stats.ClientErrorCount(), stats.ServerErrorCount())
+	}
+
+	clientErrors := stats.ClientErrors()
+	if len(clientErrors) != 3 || clientErrors[0].URL != "/a" || clientErrors[1].URL != "/c" || clientErrors[2].URL != "/e" {
+		t.Fatal("Invalid client errors", clientErrors)
+	}
+
+	serverErrors := stats.ServerErrors()
+	if len(serverErrors) != 1 || serverErrors[0].URL != "/b" {
+		t.Fatal("Invalid server errors", serverErrors)
+	}
+}
+
+func TestAsyncCrawlRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/multiple-choices":
+			w.WriteHeader(http.StatusMultipleChoices)
+		case "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{server.URL + "/", server.URL + "/multiple-choices"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || len(stats.Redirects) != 1 || len(stats.Non200Urls) != 0 {
+		t.Fatal("Expected the redirect to be reported apart from errors, got", stats.Redirects, stats.Non200Urls, err)
+	}
+
+	stats, err = crawler.AsyncCrawl(append(urls, server.URL+"/missing"), config, make(chan struct{}))
+	if err == nil || len(stats.Redirects) != 1 || len(stats.Non200Urls) != 1 {
+		t.Fatal("Expected the missing page to fail, got", stats.Redirects, stats.Non200Urls, err)
+	}
+
+	config.FailOn, _ = crawler.ParseStatusPolicy("3xx")
+	if _, err = crawler.AsyncCrawl(urls, config, make(chan struct{})); err == nil {
+		t.Fatal("Expected redirects to fail with a 3xx policy")
+	}
+}
+
+func TestAsyncCrawlSuccessCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{server.URL + "/", server.URL + "/created", server.URL + "/empty"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil || stats.Successes != 1 || len(stats.Non200Urls) != 2 {
+		t.Fatal("Expected only 200 to be a success by default, got", stats.Successes, stats.Non200Urls, err)
+	}
+
+	config.SuccessCodes, _ = crawler.ParseStatusCodes("200, 201,204")
+	stats, err = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Successes != 3 || len(stats.Times200) != 3 || len(stats.Non200Urls) != 0 ||
+		len(stats.ContentTypeMismatches) != 0 {
+		t.Fatal("Expected the success codes to be successes, got", stats.Successes, stats.Non200Urls, err)
+	}
+
+	if merged := crawler.MergeCrawlStats(stats, stats); merged.Successes != 6 || merged.Average200Time != stats.Average200Time {
+		t.Fatal("Expected successes to be merged, got", merged.Successes, merged.Average200Time)
+	}
+
+	if _, err := crawler.ParseStatusCodes("2xx"); err == nil {
+		t.Fatal("Expected an error for a status class")
+	}
+}
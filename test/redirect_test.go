@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func newRedirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusMovedPermanently))
+	mux.Handle("/b", http.RedirectHandler("/c", http.StatusFound))
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/loop1", http.RedirectHandler("/loop2", http.StatusFound))
+	mux.Handle("/loop2", http.RedirectHandler("/loop1", http.StatusFound))
+
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPGetRedirectChain(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL+"/a", crawler.HTTPConfig{})
+	if response.Err != nil || response.StatusCode != 200 {
+		t.Fatal("Expected redirects to be followed, got", response.StatusCode, response.Err)
+	}
+
+	if response.FinalURL != server.URL+"/c" ||
+		len(response.RedirectChain) != 2 ||
+		response.RedirectChain[0] != 301 || response.RedirectChain[1] != 302 {
+		t.Fatal("Invalid redirect chain", response.RedirectChain, "to", response.FinalURL)
+	}
+
+	if !response.Redirected() || crawler.HTTPGet(server.URL+"/c", crawler.HTTPConfig{}).Redirected() {
+		t.Fatal("Expected only the redirecting URL to be flagged as redirected")
+	}
+}
+
+func TestHTTPGetMaxRedirects(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL+"/a", crawler.HTTPConfig{MaxRedirects: 1})
+	if !errors.Is(response.Err, crawler.ErrTooManyRedirects) {
+		t.Fatal("Expected too many redirects error, got", response.Err)
+	}
+}
+
+func TestHTTPGetRedirectLoop(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL+"/loop1", crawler.HTTPConfig{})
+	if !errors.Is(response.Err, crawler.ErrRedirectLoop) {
+		t.Fatal("Expected redirect loop error, got", response.Err)
+	}
+}
+
+func TestAsyncCrawlRedirectedUrls(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	urls := []string{server.URL + "/a", server.URL + "/c"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || len(stats.RedirectedUrls) != 1 || stats.RedirectedUrls[0].URL != server.URL+"/a" ||
+		stats.RedirectedUrls[0].FinalURL != server.URL+"/c" {
+		t.Fatal("Expected the redirecting sitemap URL to be reported, got", stats.RedirectedUrls, err)
+	}
+
+	config.FailOnRedirects = true
+	_, err = crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err == nil {
+		t.Fatal("Expected redirecting sitemap URLs to fail the crawl")
+	}
+}
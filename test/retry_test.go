@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestRunConcurrentGetRetries(t *testing.T) {
+	var attemptsMutex sync.Mutex
+	attempts := make(map[string]int)
+
+	flakyHTTPGet := func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+		attemptsMutex.Lock()
+		defer attemptsMutex.Unlock()
+
+		attempts[url]++
+		statusCode := 200
+		if url == "flaky" && attempts[url] < 3 {
+			statusCode = 503
+		} else if url == "broken" {
+			statusCode = 500
+		}
+
+		return &crawler.HTTPResponse{URL: url, StatusCode: statusCode}
+	}
+
+	config := crawler.HTTPConfig{
+		Retry: crawler.RetryConfig{
+			MaxRetries:  3,
+			BaseBackoff: time.Millisecond,
+		},
+	}
+
+	resultChan := make(chan *crawler.HTTPResponse, 2)
+	go crawler.RunConcurrentGet(flakyHTTPGet, []string{"flaky", "broken"}, config, 2, resultChan, make(chan struct{}))
+
+	for result := range resultChan {
+		switch result.URL {
+		case "flaky":
+			if result.StatusCode != 200 || result.Attempts != 3 {
+				t.Fatal("Expected flaky URL to succeed after 3 attempts, got", result.StatusCode, "after", result.Attempts)
+			}
+		case "broken":
+			if result.StatusCode != 500 || result.Attempts != 1 {
+				t.Fatal("Expected non retryable status to be attempted once, got", result.Attempts)
+			}
+		}
+	}
+}
+
+func TestAsyncCrawlRetryAfter(t *testing.T) {
+	var attemptsMutex sync.Mutex
+	attempts := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attemptsMutex.Lock()
+		defer attemptsMutex.Unlock()
+
+		attempts[r.URL.Path]++
+		if attempts[r.URL.Path] > 1 {
+			return
+		}
+
+		switch r.URL.Path {
+		case "/seconds":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/date":
+			w.Header().Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/ignored":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   3,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP: crawler.HTTPConfig{
+			Retry: crawler.RetryConfig{
+				MaxRetries:    1,
+				BaseBackoff:   time.Millisecond,
+				MaxRetryAfter: 50 * time.Millisecond,
+			},
+		},
+	}
+
+	urls := []string{server.URL + "/seconds", server.URL + "/date", server.URL + "/ignored"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.StatusCodes[200] != 3 {
+		t.Fatal("Expected every URL to succeed once retried, got", stats.Non200Urls, err)
+	}
+
+	for _, result := range stats.Results {
+		expected := 50 * time.Millisecond
+		if result.URL == server.URL+"/ignored" {
+			expected = 0
+		}
+
+		if result.Attempts != 2 || result.RetryAfter != expected {
+			t.Fatal("Expected a Retry-After of", expected, "for", result.URL, "got", result.RetryAfter,
+				"after", result.Attempts, "attempts")
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestAsyncCrawlRespectRobotsTxt(t *testing.T) {
+	var requestedMutex sync.Mutex
+	requested := make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedMutex.Lock()
+		requested[r.URL.Path] = true
+		requestedMutex.Unlock()
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nAllow: /private/public\n")
+		case "/page":
+			fmt.Fprint(w, `<html><body>
+<a href="/private/secret">secret</a>
+<a href="/private/public">public</a>
+<a href="/other">other</a>
+</body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:         1,
+		HTTPGetter:       &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Links:            crawler.CrawlLinksConfig{CrawlHyperlinks: true},
+		RespectRobotsTxt: true,
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/page", server.URL + "/private/listed"},
+		config, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Total != 4 {
+		t.Fatal("Expected 4 URLs crawled, got", stats.Total)
+	}
+
+	if requested["/private/secret"] {
+		t.Fatal("Disallowed link was crawled")
+	}
+
+	if !requested["/private/listed"] || !requested["/private/public"] {
+		t.Fatal("Sitemap URL or explicitly allowed link was not crawled")
+	}
+}
+
+func TestAsyncCrawlRobotsTxtOncePerHost(t *testing.T) {
+	var robotsTxtCount int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			atomic.AddInt32(&robotsTxtCount, 1)
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.01\n")
+		}
+	})
+	serverA := httptest.NewServer(handler)
+	defer serverA.Close()
+	serverB := httptest.NewServer(handler)
+	defer serverB.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:             4,
+		HTTPGetter:           &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		RespectRobotsTxt:     true,
+		RobotsTxtSitemapUrls: true,
+	}
+
+	urls := []string{serverA.URL + "/a", serverA.URL + "/b", serverB.URL + "/a", serverB.URL + "/b"}
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Total != 4 {
+		t.Fatal("Expected every URL to be crawled, got", stats.Total, err)
+	}
+
+	if count := atomic.LoadInt32(&robotsTxtCount); count != 2 {
+		t.Fatal("Expected robots.txt to be fetched once per host, got", count)
+	}
+}
@@ -0,0 +1,373 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func newSitemapServer() *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+<sitemap><loc>%[1]s/nested.xml</loc></sitemap>
+<sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/nested.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/sitemap.xml</loc></sitemap>
+<sitemap><loc>%[1]s/products.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/page1</loc></url>
+<url><loc>%[1]s/page2</loc></url>
+</urlset>`, server.URL)
+	})
+	mux.HandleFunc("/compressed.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		fmt.Fprintf(writer, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/compressed</loc></url>
+</urlset>`, server.URL)
+		writer.Close()
+
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(compressed.Bytes())
+	})
+	mux.HandleFunc("/corrupted.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "not gzipped")
+	})
+	mux.HandleFunc("/duplicates.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/page1</loc></url>
+<url><loc>%[1]s/page2</loc></url>
+<url><loc>%[1]s/page1</loc></url>
+<url><loc> %[1]s/page1 </loc></url>
+</urlset>`, server.URL)
+	})
+	mux.HandleFunc("/invalid.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/page1</loc></url>
+<url><loc>%[1]s/100%%zz</loc></url>
+</urlset>`, server.URL)
+	})
+	mux.HandleFunc("/metadata.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/valid</loc><lastmod>2020-01-02T10:00:00+01:00</lastmod><changefreq>daily</changefreq><priority>0.8</priority></url>
+<url><loc>%[1]s/future</loc><lastmod>2999-01-01</lastmod></url>
+<url><loc>%[1]s/invalid-date</loc><lastmod>01/02/2020</lastmod></url>
+<url><loc>%[1]s/invalid-changefreq</loc><changefreq>sometimes</changefreq></url>
+<url><loc>%[1]s/invalid-priority</loc><priority>1.5</priority></url>
+</urlset>`, server.URL)
+	})
+	mux.HandleFunc("/oversized.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+		for i := 0; i <= crawler.MaxSitemapURLs; i++ {
+			fmt.Fprintf(w, "<url><loc>%s/%d</loc></url>\n", server.URL, i)
+		}
+		fmt.Fprint(w, "</urlset>")
+	})
+	mux.HandleFunc("/products.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/page2</loc></url>
+<url><loc>%[1]s/product1</loc></url>
+</urlset>`, server.URL)
+	})
+
+	return server
+}
+
+func TestGetSitemapUrlsRecursive(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, err := crawler.GetSitemapUrlsRecursiveAsStrings(server.URL+"/sitemap.xml", 2)
+
+	sitemapErrors, ok := err.(crawler.SitemapErrors)
+	if !ok || len(sitemapErrors) != 1 || sitemapErrors[0].SitemapURL != server.URL+"/missing.xml" {
+		t.Fatal("Expected a single error for the missing sitemap, got", err)
+	}
+
+	expected := []string{
+		server.URL + "/page1",
+		server.URL + "/page2",
+		server.URL + "/product1",
+	}
+	if !testEq(urls, expected) {
+		t.Fatal("Expected", expected, "but got", urls)
+	}
+}
+
+func TestGetSitemapUrlsRecursiveMaxDepth(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, _ := crawler.GetSitemapUrlsRecursiveAsStrings(server.URL+"/sitemap.xml", 1)
+
+	expected := []string{
+		server.URL + "/page1",
+		server.URL + "/page2",
+	}
+	if !testEq(urls, expected) {
+		t.Fatal("Expected", expected, "but got", urls)
+	}
+}
+
+func TestGetSitemapUrlsRecursiveConcurrent(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	children := []string{"slow", "broken", "fast", "last"}
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+		for _, child := range children {
+			fmt.Fprintf(w, "<sitemap><loc>%s/%s.xml</loc></sitemap>\n", server.URL, child)
+		}
+		fmt.Fprint(w, "</sitemapindex>")
+	})
+	mux.HandleFunc("/broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	for index, child := range []string{"slow", "fast", "last"} {
+		delay := time.Duration(2-index) * 150 * time.Millisecond
+		name := child
+		mux.HandleFunc("/"+name+".xml", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/%[2]s1</loc></url>
+<url><loc>%[1]s/%[2]s2</loc></url>
+</urlset>`, server.URL, name)
+		})
+	}
+
+	loader := crawler.SitemapLoader{Concurrency: 4}
+
+	start := time.Now()
+	urls, err := loader.GetSitemapUrlsRecursiveAsStrings(server.URL+"/sitemap.xml", 2)
+	elapsed := time.Since(start)
+
+	sitemapErrors, ok := err.(crawler.SitemapErrors)
+	if !ok || len(sitemapErrors) != 1 || sitemapErrors[0].SitemapURL != server.URL+"/broken.xml" {
+		t.Fatal("Expected a single error for the broken sitemap, got", err)
+	}
+
+	expected := []string{
+		server.URL + "/slow1",
+		server.URL + "/slow2",
+		server.URL + "/fast1",
+		server.URL + "/fast2",
+		server.URL + "/last1",
+		server.URL + "/last2",
+	}
+	if !testEq(urls, expected) {
+		t.Fatal("Expected the URLs in index order", expected, "but got", urls)
+	}
+
+	if elapsed >= 550*time.Millisecond {
+		t.Fatal("Expected the child sitemaps to be fetched concurrently, took", elapsed)
+	}
+}
+
+func TestSitemapLoaderHTTPConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Token")
+		if token == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>http://foo.bar/%s</loc></url>
+</urlset>`, token)
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for _, token := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			loader := crawler.SitemapLoader{HTTP: crawler.HTTPConfig{Headers: map[string]string{"X-Token": token}}}
+			for i := 0; i < 10; i++ {
+				urls, err := loader.GetSitemapUrlsAsStrings(server.URL)
+				if err != nil || len(urls) != 1 || urls[0] != "http://foo.bar/"+token {
+					t.Error("Expected the headers of the loader to be sent, got", urls, err)
+					return
+				}
+			}
+		}(token)
+	}
+	wg.Wait()
+
+	if _, err := crawler.GetSitemapUrlsRecursive(server.URL, 1); err == nil {
+		t.Fatal("Expected the default settings not to send the headers of loaders")
+	}
+}
+
+func TestGetSitemapUrlsRecursiveRootError(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, err := crawler.GetSitemapUrlsRecursive(server.URL+"/missing.xml", 1)
+	if err == nil || len(urls) != 0 {
+		t.Fatal("Expected an error for a missing root sitemap")
+	}
+
+	if _, partial := err.(crawler.SitemapErrors); partial {
+		t.Fatal("Root sitemap failure should not be reported as a partial failure")
+	}
+}
+
+func TestGetSitemapUrlsRecursiveInvalidUrls(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, err := crawler.GetSitemapUrlsRecursiveAsStrings(server.URL+"/invalid.xml", 1)
+	if !testEq(urls, []string{server.URL + "/page1"}) {
+		t.Fatal("Expected the valid URLs to be returned, got", urls)
+	}
+
+	sitemapErrors, ok := err.(crawler.SitemapErrors)
+	if !ok || len(sitemapErrors.InvalidURLs()) != 1 || sitemapErrors[0].Loc != server.URL+"/100%zz" ||
+		sitemapErrors[0].SitemapURL != server.URL+"/invalid.xml" {
+		t.Fatal("Expected the invalid entry to be returned, got", err)
+	}
+
+	if !strings.Contains(err.Error(), "1 URL(s) were invalid") {
+		t.Fatal("Expected the error to count invalid URLs, got", err)
+	}
+}
+
+func TestGetSitemapUrlsRecursiveLimits(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, err := crawler.GetSitemapUrlsRecursive(server.URL+"/oversized.xml", 1)
+	if len(urls) != crawler.MaxSitemapURLs+1 {
+		t.Fatal("Expected the URLs of an oversized sitemap to be returned, got", len(urls))
+	}
+
+	sitemapErrors, _ := err.(crawler.SitemapErrors)
+	oversized := sitemapErrors.OversizedSitemaps()
+	var limitErr *crawler.SitemapLimitError
+	if len(oversized) != 1 || !errors.As(oversized[0].Err, &limitErr) || limitErr.URLs != crawler.MaxSitemapURLs+1 ||
+		limitErr.Bytes == 0 {
+		t.Fatal("Expected the sitemap to exceed the URL limit, got", err)
+	}
+
+	_, err = crawler.GetSitemapUrlsRecursive(server.URL+"/pages.xml", 1)
+	if err != nil {
+		t.Fatal("Expected a small sitemap within the limits, got", err)
+	}
+}
+
+func TestGetSitemapUrlsGzipped(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	urls, err := crawler.GetSitemapUrlsAsStrings(server.URL + "/compressed.xml.gz")
+	if err != nil || !testEq(urls, []string{server.URL + "/compressed"}) {
+		t.Fatal("Failed to read gzipped sitemap", urls, err)
+	}
+
+	_, err = crawler.GetSitemapUrls(server.URL + "/corrupted.xml.gz")
+	if err == nil || !strings.Contains(err.Error(), "decompress") {
+		t.Fatal("Expected a decompression error, got", err)
+	}
+}
+
+func TestGetSitemapUrlsLocalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crowlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sitemap.xml")
+	err = ioutil.WriteFile(path, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>https://foo.bar/local</loc></url>
+</urlset>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sitemapURL := range []string{path, "file://" + path} {
+		urls, err := crawler.GetSitemapUrlsAsStrings(sitemapURL)
+		if err != nil || !testEq(urls, []string{"https://foo.bar/local"}) {
+			t.Fatal("Failed to read local sitemap", sitemapURL, urls, err)
+		}
+	}
+}
+
+func TestFindDuplicateSitemapUrls(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	duplicates, err := crawler.FindDuplicateSitemapUrls(server.URL + "/duplicates.xml")
+	if err != nil || len(duplicates) != 1 || duplicates[server.URL+"/page1"] != 3 {
+		t.Fatal("Expected page1 to be listed 3 times, got", duplicates, err)
+	}
+
+	duplicates, err = crawler.FindDuplicateSitemapUrls(server.URL + "/pages.xml")
+	if err != nil || len(duplicates) != 0 {
+		t.Fatal("Expected no duplicates, got", duplicates, err)
+	}
+}
+
+func TestValidateSitemap(t *testing.T) {
+	server := newSitemapServer()
+	defer server.Close()
+
+	entries, err := crawler.GetSitemapEntries(server.URL + "/metadata.xml")
+	if err != nil || len(entries) != 5 || entries[0].ChangeFreq != "daily" || entries[0].Priority != 0.8 {
+		t.Fatal("Expected the sitemap entries with their metadata, got", entries, err)
+	}
+
+	warnings, err := crawler.ValidateSitemap(server.URL + "/metadata.xml")
+	if err != nil {
+		t.Fatal("Failed to validate the sitemap", err)
+	}
+
+	var warned []string
+	for _, warning := range warnings {
+		warned = append(warned, strings.TrimPrefix(warning.Loc, server.URL)+" "+warning.Field)
+	}
+
+	expected := []string{"/future lastmod", "/invalid-date lastmod", "/invalid-changefreq changefreq",
+		"/invalid-priority priority"}
+	if !testEq(warned, expected) {
+		t.Fatal("Expected", expected, "but got", warned)
+	}
+}
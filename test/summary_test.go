@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestWriteSummary(t *testing.T) {
+	stats := crawler.CrawlStats{
+		Total:          3,
+		WallClock:      2 * time.Second,
+		StatusCodes:    map[int]int{200: 2, 404: 1},
+		Average200Time: 100 * time.Millisecond,
+		Max200Time:     150 * time.Millisecond,
+		Times200:       []time.Duration{50 * time.Millisecond, 150 * time.Millisecond},
+	}
+
+	var summary struct {
+		Schema      int            `json:"schema"`
+		Passed      bool           `json:"passed"`
+		Total       int            `json:"crawled"`
+		Failures    int            `json:"failures"`
+		WallClockMs int            `json:"wall-clock-ms"`
+		StatusCodes map[string]int `json:"status-codes"`
+		AverageMs   int            `json:"avg-time-ms"`
+		P95Ms       int            `json:"p95-time-ms"`
+	}
+
+	var output bytes.Buffer
+	err := crawler.WriteSummary(&output, stats, crawler.CrawlConfig{})
+	if err != nil || json.Unmarshal(output.Bytes(), &summary) != nil {
+		t.Fatal("Failed to write the summary", err, output.String())
+	}
+
+	if summary.Schema != crawler.SummarySchema || summary.Passed || summary.Total != 3 || summary.Failures != 1 ||
+		summary.WallClockMs != 2000 || summary.StatusCodes["404"] != 1 || summary.AverageMs != 100 ||
+		summary.P95Ms != 150 {
+		t.Fatal("Unexpected summary", output.String())
+	}
+
+	output.Reset()
+	crawler.WriteSummary(&output, stats, crawler.CrawlConfig{FailOn: func(statusCode int) bool {
+		return statusCode >= 500
+	}})
+	json.Unmarshal(output.Bytes(), &summary)
+	if !summary.Passed || summary.Failures != 0 {
+		t.Fatal("Expected the crawl to pass with a server error policy", output.String())
+	}
+}
+
+func TestWriteStatsJSON(t *testing.T) {
+	missing := crawler.CrawlResult{
+		URL:         "http://foo.bar/missing",
+		StatusCode:  404,
+		LinkingURLs: []string{"http://foo.bar/"},
+	}
+	stats := crawler.CrawlStats{
+		Total:          3,
+		StatusCodes:    map[int]int{200: 2, 404: 1},
+		Average200Time: 100 * time.Millisecond,
+		Max200Time:     150 * time.Millisecond,
+		Non200Urls:     []crawler.CrawlResult{missing},
+	}
+
+	var decoded struct {
+		Total struct {
+			Crawled int `json:"crawled"`
+		} `json:"total"`
+		Status struct {
+			StatusCodes map[string]int `json:"status-codes"`
+			Errors      []struct {
+				URL         string   `json:"url"`
+				StatusCode  int      `json:"status-code"`
+				LinkingURLs []string `json:"linking-urls"`
+			} `json:"errors"`
+		} `json:"status"`
+		ResponseTime struct {
+			AverageMs int `json:"avg-time-ms"`
+			MaxMs     int `json:"max-time-ms"`
+		} `json:"response-time"`
+	}
+
+	var output bytes.Buffer
+	err := crawler.WriteStatsJSON(&output, stats)
+	if err != nil || json.Unmarshal(output.Bytes(), &decoded) != nil {
+		t.Fatal("Failed to write the statistics", err, output.String())
+	}
+
+	if decoded.Total.Crawled != 3 || decoded.Status.StatusCodes["200"] != 2 || decoded.Status.StatusCodes["404"] != 1 ||
+		decoded.ResponseTime.AverageMs != 100 || decoded.ResponseTime.MaxMs != 150 {
+		t.Fatal("Unexpected statistics", output.String())
+	}
+
+	errors := decoded.Status.Errors
+	if len(errors) != 1 || errors[0].URL != missing.URL || errors[0].StatusCode != 404 ||
+		!reflect.DeepEqual(errors[0].LinkingURLs, missing.LinkingURLs) {
+		t.Fatal("Expected the 404 with its linking URL, got", output.String())
+	}
+}
+
+func TestStatusHistogram(t *testing.T) {
+	stats := crawler.CrawlStats{StatusCodes: map[int]int{200: 6, 301: 1, 404: 2, 410: 1, 0: 1}}
+
+	histogram := stats.StatusHistogram()
+	expected := []crawler.StatusClass{
+		{Class: "2xx", Total: 6, StatusCodes: map[int]int{200: 6}},
+		{Class: "3xx", Total: 1, StatusCodes: map[int]int{301: 1}},
+		{Class: "4xx", Total: 3, StatusCodes: map[int]int{404: 2, 410: 1}},
+		{Class: "none", Total: 1, StatusCodes: map[int]int{0: 1}},
+	}
+	if !reflect.DeepEqual(histogram, expected) {
+		t.Fatal("Expected", expected, "but got", histogram)
+	}
+
+	bars := stats.StatusHistogramBars(6)
+	if len(bars) != 9 || bars[0] != "2xx  ###### 6" || bars[4] != "4xx  ### 3" || bars[6] != "    410  # 1" {
+		t.Fatal("Invalid histogram bars", bars)
+	}
+}
@@ -0,0 +1,193 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+type concurrencyServer struct {
+	*httptest.Server
+	mutex          sync.Mutex
+	inFlight       int
+	maxConcurrency int
+}
+
+func newConcurrencyServer() *concurrencyServer {
+	server := &concurrencyServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.inFlight++
+		if server.inFlight > server.maxConcurrency {
+			server.maxConcurrency = server.inFlight
+		}
+		server.mutex.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		server.mutex.Lock()
+		server.inFlight--
+		server.mutex.Unlock()
+	}))
+
+	return server
+}
+
+func TestAsyncCrawlPerHostThrottle(t *testing.T) {
+	serverA := newConcurrencyServer()
+	defer serverA.Close()
+	serverB := newConcurrencyServer()
+	defer serverB.Close()
+
+	var urls []string
+	for i := 0; i < 4; i++ {
+		urls = append(urls, serverA.URL+"/"+strconv.Itoa(i))
+	}
+	for i := 0; i < 4; i++ {
+		urls = append(urls, serverB.URL+"/"+strconv.Itoa(i))
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:        4,
+		PerHostThrottle: 2,
+		HTTPGetter:      &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Total != len(urls) {
+		t.Fatal("Expected", len(urls), "URLs crawled without error, got", stats.Total, err)
+	}
+
+	if serverA.maxConcurrency != 2 || serverB.maxConcurrency != 2 {
+		t.Fatal("Expected 2 concurrent requests per host, got",
+			serverA.maxConcurrency, "and", serverB.maxConcurrency)
+	}
+}
+
+func TestAsyncCrawlMaxRPS(t *testing.T) {
+	config := crawler.CrawlConfig{
+		Throttle: 5,
+		MaxRPS:   20,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				return &crawler.HTTPResponse{URL: url, StatusCode: 200}
+			},
+		},
+	}
+
+	start := time.Now()
+	stats, _ := crawler.AsyncCrawl([]string{"1", "2", "3", "4", "5"}, config, make(chan struct{}))
+	elapsed := time.Since(start)
+
+	if stats.Total != 5 {
+		t.Fatal("Expected 5 URLs crawled, got", stats.Total)
+	}
+
+	// The first request is immediate, the next 4 are paced every 50ms
+	if elapsed < 180*time.Millisecond {
+		t.Fatal("Requests were not rate limited, took", elapsed)
+	}
+}
+
+func TestAsyncCrawlRampUp(t *testing.T) {
+	var mutex sync.Mutex
+	var startTimes []time.Duration
+	start := time.Now()
+
+	config := crawler.CrawlConfig{
+		Throttle: 4,
+		RampUp:   200 * time.Millisecond,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{
+			Get: func(url string, config crawler.HTTPConfig) *crawler.HTTPResponse {
+				mutex.Lock()
+				startTimes = append(startTimes, time.Since(start))
+				mutex.Unlock()
+				return &crawler.HTTPResponse{URL: url, StatusCode: 200}
+			},
+		},
+	}
+
+	stats, _ := crawler.AsyncCrawl([]string{"1", "2", "3", "4", "5", "6"}, config, make(chan struct{}))
+	if stats.Total != 6 {
+		t.Fatal("Expected 6 URLs crawled, got", stats.Total)
+	}
+
+	// The 4 first requests start every 50ms, the next ones after 200ms
+	if startTimes[0] > 40*time.Millisecond || startTimes[1] < 40*time.Millisecond ||
+		startTimes[3] < 140*time.Millisecond || startTimes[4] < 190*time.Millisecond {
+		t.Fatal("Requests were not ramped up, started at", startTimes)
+	}
+}
+
+func TestAsyncCrawlWorkers(t *testing.T) {
+	server := newConcurrencyServer()
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 8; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:   2,
+		Workers:    8,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	stats, err := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if err != nil || stats.Total != len(urls) {
+		t.Fatal("Expected", len(urls), "URLs crawled without error, got", stats.Total, err)
+	}
+
+	if server.maxConcurrency != 2 {
+		t.Fatal("Expected at most 2 concurrent requests with more workers, got", server.maxConcurrency)
+	}
+}
+
+func TestAsyncCrawlCircuitBreaker(t *testing.T) {
+	var mutex sync.Mutex
+	var requestTimes []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requestTimes = append(requestTimes, time.Now())
+		if len(requestTimes) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 5; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:        1,
+		BreakerFailures: 2,
+		BreakerCooldown: 100 * time.Millisecond,
+		HTTPGetter:      &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+	}
+
+	stats, _ := crawler.AsyncCrawl(urls, config, make(chan struct{}))
+	if stats.Total != len(urls) || stats.StatusCodes[503] != 3 {
+		t.Fatal("Expected every URL to be crawled once the host recovered, got", stats.StatusCodes)
+	}
+
+	// The host is paused after the second failure, and again after the
+	// failed probe, while the successful probe resumes requests at once
+	gaps := []time.Duration{}
+	for i := 1; i < len(requestTimes); i++ {
+		gaps = append(gaps, requestTimes[i].Sub(requestTimes[i-1]))
+	}
+	if gaps[0] >= 100*time.Millisecond || gaps[1] < 100*time.Millisecond || gaps[2] < 100*time.Millisecond ||
+		gaps[3] >= 100*time.Millisecond {
+		t.Fatal("Expected pauses after the second and third failures only, got", gaps)
+	}
+}
@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestHTTPGetTLSOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL, crawler.HTTPConfig{})
+	if response.Err == nil {
+		t.Fatal("Expected self-signed certificate to be rejected")
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{InsecureSkipVerify: true})
+	if response.Err != nil || response.StatusCode != 200 {
+		t.Fatal("Expected certificate verification to be skipped, got", response.Err)
+	}
+
+	dir, err := ioutil.TempDir("", "crowlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{CAFile: caFile, ForceHTTP2: true})
+	if response.Err != nil || response.StatusCode != 200 || response.Proto != "HTTP/2.0" {
+		t.Fatal("Expected an HTTP/2 response trusting the CA file, got", response.Proto, response.Err)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{CAFile: caFile, DisableHTTP2: true})
+	if response.Err != nil || response.Proto != "HTTP/1.1" {
+		t.Fatal("Expected an HTTP/1.1 response, got", response.Proto, response.Err)
+	}
+
+	response = crawler.HTTPGet(server.URL, crawler.HTTPConfig{CAFile: filepath.Join(dir, "missing.pem")})
+	if response.Err == nil {
+		t.Fatal("Expected an error for a missing CA file")
+	}
+}
+
+func TestHTTPGetHostResolve(t *testing.T) {
+	var host string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crowlet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test certificate is valid for example.com, which is pinned to the
+	// test server address
+	serverAddress := server.Listener.Addr().String()
+	config := crawler.HTTPConfig{
+		CAFile:      caFile,
+		HostResolve: map[string]string{"example.com:443": serverAddress},
+	}
+
+	response := crawler.HTTPGet("https://example.com/page", config)
+	if response.Err != nil || response.StatusCode != 200 || host != "example.com" {
+		t.Fatal("Expected the request to reach the pinned address as example.com, got", host, response.Err)
+	}
+
+	_, port, _ := net.SplitHostPort(serverAddress)
+	config.HostResolve = map[string]string{"example.com": "127.0.0.1"}
+	response = crawler.HTTPGet("https://example.com:"+port+"/page", config)
+	if response.Err != nil || response.StatusCode != 200 || host != "example.com:"+port {
+		t.Fatal("Expected the host pinned whatever its port, got", host, response.Err)
+	}
+}
+
+func TestHTTPGetDialNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	response := crawler.HTTPGet(server.URL+"/page", crawler.HTTPConfig{DialNetwork: "tcp4"})
+	if response.Err != nil || response.StatusCode != 200 || response.AddressFamily != "ipv4" {
+		t.Fatal("Expected the IPv4 server to be reached over IPv4, got", response.AddressFamily, response.Err)
+	}
+
+	response = crawler.HTTPGet(server.URL+"/page", crawler.HTTPConfig{DialNetwork: "tcp6"})
+	if response.Err == nil || response.AddressFamily != "" {
+		t.Fatal("Expected the IPv4 server not to be reached over IPv6, got", response.StatusCode)
+	}
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		HTTP:       crawler.HTTPConfig{DialNetwork: "tcp6"},
+	}
+	stats, _ := crawler.AsyncCrawl([]string{server.URL + "/page"}, config, make(chan struct{}))
+	if len(stats.Results) != 1 || stats.Results[0].Time < 0 || stats.Results[0].Time > time.Minute {
+		t.Fatal("Expected the failed dial to be timed from the request, got", stats.Results)
+	}
+
+	response = crawler.HTTPGet(server.URL+"/page", crawler.HTTPConfig{DialNetwork: "udp"})
+	if response.Err == nil || !strings.Contains(response.Err.Error(), "unsupported dial network") {
+		t.Fatal("Expected an error for an unsupported network, got", response.Err)
+	}
+
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available: ", err)
+	}
+	server6 := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server6.Listener.Close()
+	server6.Listener = listener
+	server6.Start()
+	defer server6.Close()
+
+	response = crawler.HTTPGet(server6.URL+"/page", crawler.HTTPConfig{DialNetwork: "tcp6"})
+	if response.Err != nil || response.StatusCode != 200 || response.AddressFamily != "ipv6" {
+		t.Fatal("Expected the IPv6 server to be reached over IPv6, got", response.AddressFamily, response.Err)
+	}
+}
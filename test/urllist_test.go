@@ -0,0 +1,23 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestGetUrlsFromReader(t *testing.T) {
+	list := `# Products
+https://foo.bar/product1
+
+  https://foo.bar/product2  
+# https://foo.bar/disabled
+`
+
+	urls, err := crawler.GetUrlsFromReader(strings.NewReader(list))
+	expected := []string{"https://foo.bar/product1", "https://foo.bar/product2"}
+	if err != nil || !testEq(urls, expected) {
+		t.Fatal("Expected", expected, "but got", urls, err)
+	}
+}
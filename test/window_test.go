@@ -0,0 +1,91 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pixep/crowlet/pkg/crawler"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	window, err := crawler.ParseTimeWindow("fri-mon,wed 22-6")
+	expectedDays := []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday, time.Wednesday}
+	if err != nil || !reflect.DeepEqual(window.Days, expectedDays) || window.StartHour != 22 || window.EndHour != 6 {
+		t.Fatal("Expected days wrapping over the week end, got", window, err)
+	}
+
+	window, err = crawler.ParseTimeWindow("0-24")
+	if err != nil || window.Days != nil || window.StartHour != 0 || window.EndHour != 0 {
+		t.Fatal("Expected every hour of every day, got", window, err)
+	}
+
+	for _, spec := range []string{"", "9", "mon", "mon-fri 9-25", "someday 9-17", "mon-fri 9-17 extra"} {
+		if _, err := crawler.ParseTimeWindow(spec); err == nil {
+			t.Error("Expected an error for", spec)
+		}
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	window := crawler.TimeWindow{
+		Days:      []time.Weekday{time.Friday},
+		StartHour: 22,
+		EndHour:   6,
+		Location:  time.UTC,
+	}
+
+	// 2021-01-01 is a Friday
+	cases := map[string]bool{
+		"2021-01-01T21:59:00Z": false,
+		"2021-01-01T22:00:00Z": true,
+		"2021-01-02T05:59:00Z": true,
+		"2021-01-02T06:00:00Z": false,
+		"2021-01-02T23:00:00Z": false,
+		"2021-01-01T03:00:00Z": false,
+	}
+	for value, expected := range cases {
+		date, _ := time.Parse(time.RFC3339, value)
+		if window.Contains(date) != expected {
+			t.Error("Expected", value, "in window to be", expected)
+		}
+	}
+
+	window = crawler.TimeWindow{StartHour: 9, EndHour: 17, Location: time.FixedZone("UTC+2", 2*3600)}
+	date, _ := time.Parse(time.RFC3339, "2021-01-01T07:30:00Z")
+	if !window.Contains(date) || window.Contains(date.Add(-time.Hour)) {
+		t.Fatal("Expected the hours of the window location to be used")
+	}
+}
+
+func TestAsyncCrawlWindow(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	config := crawler.CrawlConfig{
+		Throttle:   1,
+		HTTPGetter: &crawler.BaseConcurrentHTTPGetter{Get: crawler.HTTPGet},
+		Window:     &crawler.TimeWindow{StartHour: 0, EndHour: 0},
+	}
+
+	stats, err := crawler.AsyncCrawl([]string{server.URL + "/open"}, config, make(chan struct{}))
+	if err != nil || stats.Total != 1 {
+		t.Fatal("Expected the URL to be crawled within the window, got", stats.Total, err)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	// A whole day, three days from now
+	closedDay := (time.Now().Weekday() + 3) % 7
+	config.Window = &crawler.TimeWindow{Days: []time.Weekday{closedDay}}
+	config.MaxCrawlDuration = 200 * time.Millisecond
+	stats, _ = crawler.AsyncCrawl([]string{server.URL + "/closed"}, config, make(chan struct{}))
+	if stats.Total != 0 || atomic.LoadInt32(&requests) != 0 {
+		t.Fatal("Expected no request outside the window, got", stats.Total)
+	}
+}